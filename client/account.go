@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// SetOnline changes the "online" option, which marks the current user as online or offline.
+// TDLib goes offline by itself after a period of inactivity, so long-running clients may need to refresh it.
+func (client *Client) SetOnline(online bool) error {
+	_, err := client.SetOption(&SetOptionRequest{
+		Name: "online",
+		Value: &OptionValueBoolean{
+			Value: online,
+		},
+	})
+
+	return err
+}
+
+// BotDeepLink returns https://t.me/<bot>?start=<param> link opening a private chat with the bot.
+func (client *Client) BotDeepLink(ctx context.Context, startParam string) (string, error) {
+	return client.botDeepLink(ctx, "start", startParam)
+}
+
+// BotGroupDeepLink returns https://t.me/<bot>?startgroup=<param> link adding the bot to a group.
+func (client *Client) BotGroupDeepLink(ctx context.Context, startParam string) (string, error) {
+	return client.botDeepLink(ctx, "startgroup", startParam)
+}
+
+func (client *Client) botDeepLink(ctx context.Context, key string, startParam string) (string, error) {
+	me, err := requestAs[*User](ctx, client, "getMe", nil)
+	if err != nil {
+		return "", err
+	}
+
+	if me.Usernames == nil || len(me.Usernames.ActiveUsernames) == 0 {
+		return "", errors.New("current user has no username")
+	}
+
+	query := url.Values{}
+	query.Set(key, startParam)
+
+	return "https://t.me/" + me.Usernames.ActiveUsernames[0] + "?" + query.Encode(), nil
+}
+
+// getUserProfilePhotos returns at most 100 photos per call.
+const maxProfilePhotosPageSize = 100
+
+// ProfilePhotos returns all profile photos of the user from the newest, or an empty slice if there are none.
+func (client *Client) ProfilePhotos(ctx context.Context, userId int64) ([]*ChatPhoto, error) {
+	photos := []*ChatPhoto{}
+	for {
+		page, err := requestAs[*ChatPhotos](ctx, client, "getUserProfilePhotos", &GetUserProfilePhotosRequest{
+			UserId: userId,
+			Offset: int32(len(photos)),
+			Limit:  maxProfilePhotosPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		photos = append(photos, page.Photos...)
+		if len(page.Photos) == 0 || int32(len(photos)) >= page.TotalCount {
+			return photos, nil
+		}
+	}
+}
+
+// Accent colors with identifiers 0-6 are always supported and aren't sent in updateAccentColors.
+const builtinAccentColorCount = 7
+
+var ErrInvalidAccentColor = errors.New("accent color is not available")
+
+func (client *Client) setAccentColors(update *UpdateAccentColors) {
+	client.accentColorsMu.Lock()
+	defer client.accentColorsMu.Unlock()
+
+	client.accentColorIds = update.AvailableAccentColorIds
+}
+
+// AccentColorIds returns accent color identifiers that can be set, as received in the last updateAccentColors.
+// Returns nil if the update hasn't been received yet.
+func (client *Client) AccentColorIds() []int32 {
+	client.accentColorsMu.Lock()
+	defer client.accentColorsMu.Unlock()
+
+	if client.accentColorIds == nil {
+		return nil
+	}
+
+	return append([]int32{}, client.accentColorIds...)
+}
+
+func (client *Client) isAvailableAccentColor(colorId int32) bool {
+	if colorId < 0 {
+		return false
+	}
+	if colorId < builtinAccentColorCount {
+		return true
+	}
+
+	ids := client.AccentColorIds()
+	if ids == nil {
+		// Let TDLib decide while the list is unknown.
+		return true
+	}
+	for _, id := range ids {
+		if id == colorId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetMyAccentColor changes accent color of the current user and custom emoji shown on the reply header and link preview background.
+// Pass 0 as backgroundCustomEmojiId to remove the emoji.
+func (client *Client) SetMyAccentColor(ctx context.Context, colorId int32, backgroundCustomEmojiId int64) error {
+	if !client.isAvailableAccentColor(colorId) {
+		return fmt.Errorf("%w: %d", ErrInvalidAccentColor, colorId)
+	}
+
+	_, err := client.request(ctx, "setAccentColor", &SetAccentColorRequest{
+		AccentColorId:           colorId,
+		BackgroundCustomEmojiId: JsonInt64(backgroundCustomEmojiId),
+	})
+	return err
+}
+
+// PremiumLimit returns default and Telegram Premium values of the limit, e.g. &PremiumLimitTypeChatFolderCount{}.
+func (client *Client) PremiumLimit(ctx context.Context, limitType PremiumLimitType) (*PremiumLimit, error) {
+	if limitType == nil {
+		return nil, errors.New("premium limit type is required")
+	}
+
+	limit, err := requestAs[*PremiumLimit](ctx, client, "getPremiumLimit", &GetPremiumLimitRequest{
+		LimitType: limitType,
+	})
+
+	return limit, err
+}
@@ -1,8 +1,11 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -45,6 +48,39 @@ func Authorize(client *Client, authorizationStateHandler AuthorizationStateHandl
 	}
 }
 
+// AuthorizeWithParameters works like Authorize, but sets TDLib parameters itself,
+// so the handler is only asked for credentials, e.g. phone number, code and password.
+func AuthorizeWithParameters(client *Client, params *SetTdlibParametersRequest, handler AuthorizationStateHandler) error {
+	return Authorize(client, ParametersAuthorizer(params, handler))
+}
+
+type parametersAuthorizer struct {
+	params  *SetTdlibParametersRequest
+	handler AuthorizationStateHandler
+}
+
+// ParametersAuthorizer answers AuthorizationStateWaitTdlibParameters with params and passes other states to handler,
+// e.g. to be passed to NewClient.
+func ParametersAuthorizer(params *SetTdlibParametersRequest, handler AuthorizationStateHandler) AuthorizationStateHandler {
+	return &parametersAuthorizer{
+		params:  params,
+		handler: handler,
+	}
+}
+
+func (stateHandler *parametersAuthorizer) Handle(client *Client, state AuthorizationState) error {
+	if state.AuthorizationStateType() == TypeAuthorizationStateWaitTdlibParameters {
+		_, err := client.SetTdlibParameters(stateHandler.params)
+		return err
+	}
+
+	return stateHandler.handler.Handle(client, state)
+}
+
+func (stateHandler *parametersAuthorizer) Close() {
+	stateHandler.handler.Close()
+}
+
 type clientAuthorizer struct {
 	TdlibParameters chan *SetTdlibParametersRequest
 	PhoneNumber     chan string
@@ -162,6 +198,84 @@ func CliInteractor(clientAuthorizer *clientAuthorizer) {
 	}
 }
 
+// QR code login is confirmed on another device, so the state is polled meanwhile.
+const qrCodePollInterval = time.Second
+
+type qrCodeAuthorizer struct {
+	TdlibParameters chan *SetTdlibParametersRequest
+	// tg:// links to be shown as QR code, the link is refreshed by TDLib periodically.
+	Link     chan string
+	Password chan string
+	State    chan AuthorizationState
+	lastLink string
+}
+
+// QrCodeAuthorizer logs in by scanning a QR code in an already authorized app.
+// Render each link received from Link channel; send the 2FA password to Password channel if the account has one.
+func QrCodeAuthorizer() *qrCodeAuthorizer {
+	return &qrCodeAuthorizer{
+		TdlibParameters: make(chan *SetTdlibParametersRequest, 1),
+		Link:            make(chan string, 1),
+		Password:        make(chan string, 1),
+		State:           make(chan AuthorizationState, 10),
+	}
+}
+
+func (stateHandler *qrCodeAuthorizer) Handle(client *Client, state AuthorizationState) error {
+	// Don't flood State channel while the state is polled.
+	if confirmation, ok := state.(*AuthorizationStateWaitOtherDeviceConfirmation); !ok || confirmation.Link != stateHandler.lastLink {
+		stateHandler.State <- state
+	}
+
+	switch state.AuthorizationStateType() {
+	case TypeAuthorizationStateWaitTdlibParameters:
+		_, err := client.SetTdlibParameters(<-stateHandler.TdlibParameters)
+		return err
+
+	case TypeAuthorizationStateWaitPhoneNumber:
+		_, err := client.RequestQrCodeAuthentication(&RequestQrCodeAuthenticationRequest{})
+		return err
+
+	case TypeAuthorizationStateWaitOtherDeviceConfirmation:
+		link := state.(*AuthorizationStateWaitOtherDeviceConfirmation).Link
+		if link != stateHandler.lastLink {
+			stateHandler.lastLink = link
+			// Keep only the latest link if the previous one wasn't read.
+			select {
+			case <-stateHandler.Link:
+			default:
+			}
+			stateHandler.Link <- link
+		}
+		time.Sleep(qrCodePollInterval)
+		return nil
+
+	case TypeAuthorizationStateWaitPassword:
+		_, err := client.CheckAuthenticationPassword(&CheckAuthenticationPasswordRequest{
+			Password: <-stateHandler.Password,
+		})
+		return err
+
+	case TypeAuthorizationStateReady:
+		return nil
+
+	case TypeAuthorizationStateClosing:
+		return nil
+
+	case TypeAuthorizationStateClosed:
+		return nil
+	}
+
+	return ErrNotSupportedAuthorizationState
+}
+
+func (stateHandler *qrCodeAuthorizer) Close() {
+	close(stateHandler.TdlibParameters)
+	close(stateHandler.Link)
+	close(stateHandler.Password)
+	close(stateHandler.State)
+}
+
 type botAuthorizer struct {
 	TdlibParameters chan *SetTdlibParametersRequest
 	Token           chan string
@@ -221,3 +335,121 @@ func (stateHandler *botAuthorizer) Close() {
 	close(stateHandler.Token)
 	close(stateHandler.State)
 }
+
+type botTokenAuthorizer struct {
+	token      string
+	parameters *SetTdlibParametersRequest
+}
+
+// BotTokenAuthorizer logs in with the bot token:
+//
+//	client, err := NewClient(BotTokenAuthorizer(os.Getenv("BOT_TOKEN")))
+//
+// TDLib parameters may be passed as params, otherwise they are made of API_ID and API_HASH environment variables
+// with databases in ./tdlib-db and ./tdlib-files directories; they are kept for reconnects.
+// It handles AuthorizationStateWaitTdlibParameters by setting the parameters, AuthorizationStateWaitPhoneNumber
+// by checking the token, AuthorizationStateReady, once Authorize returns, and closing states like other handlers.
+// Any other state, e.g. AuthorizationStateWaitCode, is answered with ErrNotSupportedAuthorizationState.
+func BotTokenAuthorizer(token string, params ...*SetTdlibParametersRequest) *botTokenAuthorizer {
+	stateHandler := &botTokenAuthorizer{
+		token: token,
+	}
+	if len(params) > 0 {
+		stateHandler.parameters = params[0]
+	}
+
+	return stateHandler
+}
+
+// envTdlibParameters returns TDLib parameters of BotTokenAuthorizer made of environment variables.
+func envTdlibParameters() (*SetTdlibParametersRequest, error) {
+	apiId, err := strconv.ParseInt(os.Getenv("API_ID"), 10, 32)
+	if err != nil || os.Getenv("API_HASH") == "" {
+		return nil, errors.New("tdlib parameters aren't set and API_ID or API_HASH environment variable is missing")
+	}
+
+	return &SetTdlibParametersRequest{
+		DatabaseDirectory:   "./tdlib-db",
+		FilesDirectory:      "./tdlib-files",
+		UseFileDatabase:     true,
+		UseChatInfoDatabase: true,
+		UseMessageDatabase:  true,
+		ApiId:               int32(apiId),
+		ApiHash:             os.Getenv("API_HASH"),
+		SystemLanguageCode:  "en",
+		DeviceModel:         "gotdlib",
+		ApplicationVersion:  "1.0",
+	}, nil
+}
+
+func (stateHandler *botTokenAuthorizer) Handle(client *Client, state AuthorizationState) error {
+	switch state.AuthorizationStateType() {
+	case TypeAuthorizationStateWaitTdlibParameters:
+		if stateHandler.parameters == nil {
+			parameters, err := envTdlibParameters()
+			if err != nil {
+				return err
+			}
+			stateHandler.parameters = parameters
+		}
+		_, err := client.SetTdlibParameters(stateHandler.parameters)
+		return err
+
+	case TypeAuthorizationStateWaitPhoneNumber:
+		if stateHandler.token == "" {
+			return errors.New("bot token is empty")
+		}
+		_, err := client.CheckAuthenticationBotToken(&CheckAuthenticationBotTokenRequest{
+			Token: stateHandler.token,
+		})
+		return err
+
+	case TypeAuthorizationStateReady:
+		return nil
+
+	case TypeAuthorizationStateClosing:
+		return nil
+
+	case TypeAuthorizationStateClosed:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrNotSupportedAuthorizationState, state.AuthorizationStateType())
+}
+
+// Close does nothing, since Handle never waits for input.
+func (stateHandler *botTokenAuthorizer) Close() {}
+
+// AuthState actively requests the current authorization state from TDLib,
+// instead of relying on updateAuthorizationState updates observed so far.
+func (client *Client) AuthState(ctx context.Context) (AuthorizationState, error) {
+	state, err := requestAs[AuthorizationState](ctx, client, "getAuthorizationState", nil)
+
+	return state, err
+}
+
+// WaitAuthState blocks until the authorization state of the given type, e.g. TypeAuthorizationStateWaitCode,
+// is current, returning immediately if it is already.
+func (client *Client) WaitAuthState(ctx context.Context, stateType string) (AuthorizationState, error) {
+	waiter := client.waitFor(func(update Type) bool {
+		upd, ok := update.(*UpdateAuthorizationState)
+		return ok && upd.AuthorizationState.AuthorizationStateType() == stateType
+	})
+
+	state, err := client.AuthState(ctx)
+	if err != nil {
+		waiter.stop()
+		return nil, err
+	}
+	if state.AuthorizationStateType() == stateType {
+		waiter.stop()
+		return state, nil
+	}
+
+	update, err := waiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return update.(*UpdateAuthorizationState).AuthorizationState, nil
+}
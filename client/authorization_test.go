@@ -0,0 +1,98 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+type failingAuthorizer struct {
+	err error
+}
+
+func (stateHandler *failingAuthorizer) Handle(client *Client, state AuthorizationState) error {
+	return stateHandler.err
+}
+
+func (stateHandler *failingAuthorizer) Close() {}
+
+var errAuthorizationFailed = errors.New("authorization failed")
+
+func TestNewClientStopsOnAuthorizeError(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+
+	var closed int32
+	transport := newQueueTransport(func(req Request) []string {
+		switch req.Type {
+		case "close":
+			atomic.StoreInt32(&closed, 1)
+			return []string{withExtra(`{"@type":"ok"}`, req.Extra)}
+		case "getAuthorizationState":
+			if atomic.LoadInt32(&closed) == 1 {
+				return []string{withExtra(`{"@type":"authorizationStateClosed"}`, req.Extra)}
+			}
+			return []string{withExtra(`{"@type":"authorizationStateWaitTdlibParameters"}`, req.Extra)}
+		}
+		return nil
+	})
+
+	client, err := NewClient(&failingAuthorizer{err: errAuthorizationFailed}, WithJsonClient(transport))
+	if !errors.Is(err, errAuthorizationFailed) || client != nil {
+		t.Fatalf("expected authorization error, got %v", err)
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestBotTokenAuthorizer(t *testing.T) {
+	t.Setenv("API_ID", "12345")
+	t.Setenv("API_HASH", "hash")
+
+	var mu sync.Mutex
+	state := "authorizationStateWaitTdlibParameters"
+	var apiId interface{}
+	var token interface{}
+	transport := newQueueTransport(func(req Request) []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.Type {
+		case "setTdlibParameters":
+			apiId = req.Data["api_id"]
+			state = "authorizationStateWaitPhoneNumber"
+		case "checkAuthenticationBotToken":
+			token = req.Data["token"]
+			state = "authorizationStateReady"
+		case "getAuthorizationState":
+			return []string{withExtra(fmt.Sprintf(`{"@type":%q}`, state), req.Extra)}
+		default:
+			return nil
+		}
+		return []string{withExtra(`{"@type":"ok"}`, req.Extra)}
+	})
+
+	client, err := NewClient(BotTokenAuthorizer("bot-token"), WithJsonClient(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if apiId != int32(12345) || token != "bot-token" {
+		t.Fatalf("unexpected api_id %v and token %v", apiId, token)
+	}
+}
+
+func TestBotTokenAuthorizerWithoutParameters(t *testing.T) {
+	t.Setenv("API_ID", "")
+
+	err := BotTokenAuthorizer("bot-token").Handle(nil, &AuthorizationStateWaitTdlibParameters{})
+	if err == nil {
+		t.Fatal("expected missing parameters error")
+	}
+}
@@ -0,0 +1,15 @@
+package client
+
+import (
+	"context"
+)
+
+// AnswerCallbackURL answers the callback query with a URL to be opened by the user,
+// e.g. a t.me link of a game or a Web App. Bots only.
+func (client *Client) AnswerCallbackURL(ctx context.Context, queryId int64, url string) error {
+	_, err := client.request(ctx, "answerCallbackQuery", &AnswerCallbackQueryRequest{
+		CallbackQueryId: JsonInt64(queryId),
+		Url:             url,
+	})
+	return err
+}
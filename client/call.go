@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNoVideoChat = errors.New("chat has no active video chat")
+
+// videoChatId returns group call identifier of the active video chat of the chat.
+func (client *Client) videoChatId(ctx context.Context, chatId int64) (int32, error) {
+	chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if chat.VideoChat == nil || chat.VideoChat.GroupCallId == 0 {
+		return 0, ErrNoVideoChat
+	}
+
+	return chat.VideoChat.GroupCallId, nil
+}
+
+// VideoChat returns the active video chat of the chat, or ErrNoVideoChat.
+func (client *Client) VideoChat(ctx context.Context, chatId int64) (*GroupCall, error) {
+	groupCallId, err := client.videoChatId(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	return requestAs[*GroupCall](ctx, client, "getGroupCall", &GetGroupCallRequest{
+		GroupCallId: groupCallId,
+	})
+}
+
+// JoinVideoChat joins the active video chat of the chat as self. audioSourceId and payload are produced by tgcalls,
+// the returned join response payload must be passed back to it.
+func (client *Client) JoinVideoChat(ctx context.Context, chatId int64, audioSourceId int32, payload string, muted bool) (string, error) {
+	groupCallId, err := client.videoChatId(ctx, chatId)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := requestAs[*Text](ctx, client, "joinGroupCall", &JoinGroupCallRequest{
+		GroupCallId:   groupCallId,
+		AudioSourceId: audioSourceId,
+		Payload:       payload,
+		IsMuted:       muted,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return response.Text, nil
+}
+
+// LeaveVideoChat leaves the active video chat of the chat.
+func (client *Client) LeaveVideoChat(ctx context.Context, chatId int64) error {
+	groupCallId, err := client.videoChatId(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.request(ctx, "leaveGroupCall", &LeaveGroupCallRequest{
+		GroupCallId: groupCallId,
+	})
+	return err
+}
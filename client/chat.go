@@ -0,0 +1,505 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RecommendedChats returns channels similar to the given channel.
+// Pass 0 as chatId to get the general list of recommended channels.
+func (client *Client) RecommendedChats(ctx context.Context, chatId int64) ([]int64, error) {
+	var chats *Chats
+	var err error
+	if chatId == 0 {
+		chats, err = requestAs[*Chats](ctx, client, "getRecommendedChats", nil)
+	} else {
+		chats, err = requestAs[*Chats](ctx, client, "getChatSimilarChats", &GetChatSimilarChatsRequest{
+			ChatId: chatId,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return chats.ChatIds, nil
+}
+
+// TopChats returns frequently used chats of the given category, limit is up to 30.
+func (client *Client) TopChats(ctx context.Context, category TopChatCategory, limit int32) ([]int64, error) {
+	chats, err := requestAs[*Chats](ctx, client, "getTopChats", &GetTopChatsRequest{
+		Category: category,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chats.ChatIds, nil
+}
+
+// TDLib remembers up to 50 recently opened chats.
+const maxRecentChats = 50
+
+// RecentChats returns ids of chats recently opened in chat search, the most recent first.
+// They are tracked by TDLib separately from the main chat list order.
+func (client *Client) RecentChats(ctx context.Context) ([]int64, error) {
+	chats, err := requestAs[*Chats](ctx, client, "getRecentlyOpenedChats", &GetRecentlyOpenedChatsRequest{
+		Limit: maxRecentChats,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chats.ChatIds, nil
+}
+
+// How long OpenAndGetHistory waits for TDLib to load the last message of the opened chat.
+const openChatWaitTimeout = 1 * time.Second
+
+// getChatHistory returns at most 100 messages per call.
+const maxHistoryPageSize = 100
+
+// OpenAndGetHistory opens the chat, waits briefly for its last message to be loaded
+// and returns up to limit messages starting from the last one.
+// The chat stays opened until the returned function is called.
+func (client *Client) OpenAndGetHistory(ctx context.Context, chatId int64, limit int32) ([]*Message, func() error, error) {
+	waiter := client.waitFor(func(update Type) bool {
+		upd, ok := update.(*UpdateChatLastMessage)
+		return ok && upd.ChatId == chatId
+	})
+
+	_, err := client.request(ctx, "openChat", &OpenChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		waiter.stop()
+		return nil, nil, err
+	}
+
+	closeChat := func() error {
+		_, err := client.CloseChat(&CloseChatRequest{
+			ChatId: chatId,
+		})
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, openChatWaitTimeout)
+	_, _ = waiter.Wait(waitCtx)
+	cancel()
+
+	var messages []*Message
+	var fromMessageId int64
+	for int32(len(messages)) < limit {
+		pageLimit := limit - int32(len(messages))
+		if pageLimit > maxHistoryPageSize {
+			pageLimit = maxHistoryPageSize
+		}
+
+		page, err := requestAs[*Messages](ctx, client, "getChatHistory", &GetChatHistoryRequest{
+			ChatId:        chatId,
+			FromMessageId: fromMessageId,
+			Limit:         pageLimit,
+		})
+		if err != nil {
+			_ = closeChat()
+			return nil, nil, err
+		}
+		if len(page.Messages) == 0 {
+			break
+		}
+
+		messages = append(messages, page.Messages...)
+		fromMessageId = page.Messages[len(page.Messages)-1].Id
+	}
+
+	return messages, closeChat, nil
+}
+
+// NotificationSettings returns current notification settings of the chat.
+func (client *Client) NotificationSettings(ctx context.Context, chatId int64) (*ChatNotificationSettings, error) {
+	chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chat.NotificationSettings, nil
+}
+
+// UpdateNotificationSettings fetches notification settings of the chat, applies mutate and saves the result,
+// so fields not touched by mutate keep their current values.
+// Remember to clear the matching UseDefault* flag when changing a value, otherwise it is ignored.
+func (client *Client) UpdateNotificationSettings(ctx context.Context, chatId int64, mutate func(settings *ChatNotificationSettings)) error {
+	settings, err := client.NotificationSettings(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	mutate(settings)
+
+	_, err = client.request(ctx, "setChatNotificationSettings", &SetChatNotificationSettingsRequest{
+		ChatId:               chatId,
+		NotificationSettings: settings,
+	})
+	return err
+}
+
+var ErrCannotLeavePrivateChat = errors.New("private and secret chats can't be left, use RemoveChat instead")
+
+// Leave removes the current user from the basic group, supergroup or channel.
+// The chat is kept in the chat list, use RemoveChat to remove it locally too.
+func (client *Client) Leave(ctx context.Context, chatId int64) error {
+	chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch chat.Type.(type) {
+	case *ChatTypePrivate, *ChatTypeSecret:
+		return ErrCannotLeavePrivateChat
+	}
+
+	_, err = client.request(ctx, "leaveChat", &LeaveChatRequest{
+		ChatId: chatId,
+	})
+
+	var respErr ResponseError
+	if errors.As(err, &respErr) && strings.Contains(respErr.Err.Message, "CREATOR") {
+		return fmt.Errorf("chat %d: the owner can't leave the chat, transfer the ownership first: %w", chatId, err)
+	}
+
+	return err
+}
+
+// RemoveChat leaves the chat if needed and deletes its history for the current user only,
+// removing it from all chat lists. Messages of other members are not affected.
+func (client *Client) RemoveChat(ctx context.Context, chatId int64) error {
+	err := client.Leave(ctx, chatId)
+	if err != nil && err != ErrCannotLeavePrivateChat {
+		return err
+	}
+
+	_, err = client.request(ctx, "deleteChatHistory", &DeleteChatHistoryRequest{
+		ChatId:             chatId,
+		RemoveFromChatList: true,
+	})
+	return err
+}
+
+// Slow mode delays accepted by Telegram, in seconds.
+var slowModeDelays = []int32{0, 10, 30, 60, 300, 900, 3600}
+
+// SetSlowMode changes the slow mode delay of the supergroup chat, pass 0 to disable it.
+// Only 0, 10, 30, 60, 300, 900 and 3600 seconds are allowed.
+func (client *Client) SetSlowMode(ctx context.Context, chatId int64, seconds int32) error {
+	valid := false
+	for _, delay := range slowModeDelays {
+		if seconds == delay {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid slow mode delay %d, must be one of %v", seconds, slowModeDelays)
+	}
+
+	_, err := client.request(ctx, "setChatSlowModeDelay", &SetChatSlowModeDelayRequest{
+		ChatId:        chatId,
+		SlowModeDelay: seconds,
+	})
+	return err
+}
+
+// PinChat pins or unpins the chat in the chat list, nil list means the main chat list.
+func (client *Client) PinChat(ctx context.Context, chatId int64, list ChatList, pinned bool) error {
+	if list == nil {
+		list = &ChatListMain{}
+	}
+
+	_, err := client.request(ctx, "toggleChatIsPinned", &ToggleChatIsPinnedRequest{
+		ChatList: list,
+		ChatId:   chatId,
+		IsPinned: pinned,
+	})
+	return err
+}
+
+// ReorderPinnedChats sets the full order of pinned chats in the chat list, nil list means the main chat list.
+func (client *Client) ReorderPinnedChats(ctx context.Context, list ChatList, chatIds []int64) error {
+	if list == nil {
+		list = &ChatListMain{}
+	}
+
+	_, err := client.request(ctx, "setPinnedChats", &SetPinnedChatsRequest{
+		ChatList: list,
+		ChatIds:  chatIds,
+	})
+	return err
+}
+
+// Admins returns the owner and administrators of the chat, works for all kinds of groups and channels.
+func (client *Client) Admins(ctx context.Context, chatId int64) ([]*ChatAdministrator, error) {
+	admins, err := requestAs[*ChatAdministrators](ctx, client, "getChatAdministrators", &GetChatAdministratorsRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return admins.Administrators, nil
+}
+
+// Telegram accepts up to 1024 characters of report details.
+const maxReportTextLength = 1024
+
+// ReportReasonKind is a reason of Report, which is passed as is, e.g. client.Report(ctx, chatId, ReportSpam, nil, "").
+type ReportReasonKind string
+
+const (
+	ReportSpam              ReportReasonKind = TypeReportReasonSpam
+	ReportViolence          ReportReasonKind = TypeReportReasonViolence
+	ReportPornography       ReportReasonKind = TypeReportReasonPornography
+	ReportChildAbuse        ReportReasonKind = TypeReportReasonChildAbuse
+	ReportCopyright         ReportReasonKind = TypeReportReasonCopyright
+	ReportUnrelatedLocation ReportReasonKind = TypeReportReasonUnrelatedLocation
+	ReportFake              ReportReasonKind = TypeReportReasonFake
+	ReportIllegalDrugs      ReportReasonKind = TypeReportReasonIllegalDrugs
+	ReportPersonalDetails   ReportReasonKind = TypeReportReasonPersonalDetails
+	// The reason is described by the report text.
+	ReportCustom ReportReasonKind = TypeReportReasonCustom
+)
+
+var reportReasonKinds = map[ReportReasonKind]struct{}{
+	ReportSpam:              {},
+	ReportViolence:          {},
+	ReportPornography:       {},
+	ReportChildAbuse:        {},
+	ReportCopyright:         {},
+	ReportUnrelatedLocation: {},
+	ReportFake:              {},
+	ReportIllegalDrugs:      {},
+	ReportPersonalDetails:   {},
+	ReportCustom:            {},
+}
+
+func (kind ReportReasonKind) ReportReasonType() string {
+	return string(kind)
+}
+
+func (kind ReportReasonKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"@type": string(kind),
+	})
+}
+
+// Report reports the chat or some of its messages to Telegram moderators, e.g. with ReportSpam reason.
+// Pass no messageIds to report the whole chat.
+func (client *Client) Report(ctx context.Context, chatId int64, reason ReportReason, messageIds []int64, text string) error {
+	if reason == nil {
+		return errors.New("report reason is required")
+	}
+	if kind, ok := reason.(ReportReasonKind); ok {
+		if _, known := reportReasonKinds[kind]; !known {
+			return fmt.Errorf("unknown report reason %q", kind)
+		}
+		if kind == ReportCustom && strings.TrimSpace(text) == "" {
+			return errors.New("custom report reason requires text")
+		}
+	}
+	if utf8.RuneCountInString(text) > maxReportTextLength {
+		return fmt.Errorf("report text is too long, max %d characters", maxReportTextLength)
+	}
+
+	_, err := client.request(ctx, "reportChat", &ReportChatRequest{
+		ChatId:     chatId,
+		MessageIds: messageIds,
+		Reason:     reason,
+		Text:       text,
+	})
+	return err
+}
+
+// TDLib returns up to 100 chat events at once.
+const maxChatEventLogPageSize = 100
+
+// ChatEventLog returns admin actions in the supergroup or channel during the last 48 hours, newest first.
+// Pass 0 as fromEventId to start from the latest event and nil filters to get events of all types.
+// Limit is capped to 100; use event id of the last returned event as fromEventId to get the next page.
+func (client *Client) ChatEventLog(ctx context.Context, chatId int64, query string, fromEventId int64, limit int32, filters *ChatEventLogFilters, userIds []int64) ([]*ChatEvent, error) {
+	if limit <= 0 || limit > maxChatEventLogPageSize {
+		limit = maxChatEventLogPageSize
+	}
+
+	events, err := requestAs[*ChatEvents](ctx, client, "getChatEventLog", &GetChatEventLogRequest{
+		ChatId:      chatId,
+		Query:       query,
+		FromEventId: JsonInt64(fromEventId),
+		Limit:       limit,
+		Filters:     filters,
+		UserIds:     userIds,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events.Events, nil
+}
+
+// SetContentProtection enables or disables forwarding and saving of messages from the chat.
+func (client *Client) SetContentProtection(ctx context.Context, chatId int64, enabled bool) error {
+	_, err := client.request(ctx, "toggleChatHasProtectedContent", &ToggleChatHasProtectedContentRequest{
+		ChatId:              chatId,
+		HasProtectedContent: enabled,
+	})
+	return err
+}
+
+// SetJoinByRequest makes users joining the supergroup by its link or username be approved by administrators.
+func (client *Client) SetJoinByRequest(ctx context.Context, chatId int64, enabled bool) error {
+	chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return err
+	}
+
+	supergroup, ok := chat.Type.(*ChatTypeSupergroup)
+	if !ok || supergroup.IsChannel {
+		return fmt.Errorf("chat %d is not a supergroup", chatId)
+	}
+
+	_, err = client.request(ctx, "toggleSupergroupJoinByRequest", &ToggleSupergroupJoinByRequestRequest{
+		SupergroupId:  supergroup.SupergroupId,
+		JoinByRequest: enabled,
+	})
+	return err
+}
+
+// AllReactions passed to SetAvailableReactions allows all reactions in the chat.
+var AllReactions = []string{"*"}
+
+// Telegram allows up to 11 different reactions per message.
+const maxReactionCount = 11
+
+// SetAvailableReactions sets emoji reactions allowed in the chat. Pass nil or an empty slice to disable reactions
+// and AllReactions to allow any reaction.
+func (client *Client) SetAvailableReactions(ctx context.Context, chatId int64, reactions []string) error {
+	var available ChatAvailableReactions
+	if len(reactions) == 1 && reactions[0] == AllReactions[0] {
+		available = &ChatAvailableReactionsAll{
+			MaxReactionCount: maxReactionCount,
+		}
+	} else {
+		types := make([]ReactionType, 0, len(reactions))
+		for _, emoji := range reactions {
+			types = append(types, &ReactionTypeEmoji{
+				Emoji: emoji,
+			})
+		}
+		available = &ChatAvailableReactionsSome{
+			Reactions:        types,
+			MaxReactionCount: maxReactionCount,
+		}
+	}
+
+	_, err := client.request(ctx, "setChatAvailableReactions", &SetChatAvailableReactionsRequest{
+		ChatId:             chatId,
+		AvailableReactions: available,
+	})
+	return err
+}
+
+// MessageSenders returns senders which can be used to send messages in the chat, e.g. the current user and
+// channels owned by it. Senders requiring Telegram Premium are included too.
+func (client *Client) MessageSenders(ctx context.Context, chatId int64) ([]MessageSender, error) {
+	senders, err := requestAs[*ChatMessageSenders](ctx, client, "getChatAvailableMessageSenders", &GetChatAvailableMessageSendersRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MessageSender, 0, len(senders.Senders))
+	for _, sender := range senders.Senders {
+		result = append(result, sender.Sender)
+	}
+
+	return result, nil
+}
+
+// SetMessageSender selects the default sender of messages in the chat, one of MessageSenders.
+func (client *Client) SetMessageSender(ctx context.Context, chatId int64, sender MessageSender) error {
+	if sender == nil {
+		return errors.New("message sender is required")
+	}
+
+	_, err := client.request(ctx, "setChatMessageSender", &SetChatMessageSenderRequest{
+		ChatId:          chatId,
+		MessageSenderId: sender,
+	})
+	return err
+}
+
+// How many chats MarkChatsRead marks at once.
+const markChatsReadConcurrency = 5
+
+// MarkChatsRead marks all messages of the chats as read by viewing their last messages, a few chats at a time.
+// All chats are tried, the first error is returned.
+func (client *Client) MarkChatsRead(ctx context.Context, chatIds []int64) error {
+	slots := make(chan struct{}, markChatsReadConcurrency)
+	errs := make(chan error, len(chatIds))
+
+	var wg sync.WaitGroup
+	for _, chatId := range chatIds {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(chatId int64) {
+			defer wg.Done()
+			defer func() {
+				<-slots
+			}()
+
+			if err := client.markChatRead(ctx, chatId); err != nil {
+				errs <- fmt.Errorf("chat %d: %w", chatId, err)
+			}
+		}(chatId)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func (client *Client) markChatRead(ctx context.Context, chatId int64) error {
+	chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return err
+	}
+	if chat.LastMessage == nil || chat.UnreadCount == 0 {
+		return nil
+	}
+
+	_, err = client.request(ctx, "viewMessages", &ViewMessagesRequest{
+		ChatId:     chatId,
+		MessageIds: []int64{chat.LastMessage.Id},
+		ForceRead:  true,
+	})
+	return err
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestReportReasonKind(t *testing.T) {
+	client, transport := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"ok"}`, req["@extra"])}
+	})
+	defer client.Stop()
+
+	ctx := context.Background()
+	if err := client.Report(ctx, 1, ReportSpam, []int64{2}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Report(ctx, 1, &ReportReasonFake{}, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Report(ctx, 1, ReportCustom, nil, ""); err == nil {
+		t.Fatal("custom reason without text is reported")
+	}
+	if err := client.Report(ctx, 1, ReportReasonKind("reportReasonUnknown"), nil, ""); err == nil {
+		t.Fatal("unknown reason is reported")
+	}
+
+	sent := transport.requests()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(sent))
+	}
+	for i, want := range []string{TypeReportReasonSpam, TypeReportReasonFake} {
+		reason, _ := sent[i]["reason"].(map[string]interface{})
+		if sent[i]["@type"] != "reportChat" || fmt.Sprint(reason["@type"]) != want {
+			t.Errorf("unexpected request %v", sent[i])
+		}
+	}
+}
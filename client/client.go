@@ -1,31 +1,183 @@
 package client
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"runtime/pprof"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var pendingUpdateType []Type
 
+var ErrClientStopped = errors.New("client is stopped")
+
 type Client struct {
+	// Accessed atomically, keep them first for 64-bit alignment.
+	updateSeq       uint64
+	pendingRequests int64
+	lastUpdate      int64
+	authorized      int32
+	reconnecting    int32
+
+	// Guards jsonClient and transport replaced on reconnect.
+	transportMu     sync.RWMutex
 	jsonClient      *JsonClient
+	name            string
 	extraGenerator  ExtraGenerator
 	responses       chan *Response
 	pendingResp     chan *Response
 	listenerStore   *listenerStore
 	catchersStore   *sync.Map
 	successMsgStore *sync.Map
+	transport       Transport
+	marshal         func(v interface{}) ([]byte, error)
+	unmarshal       func(data []byte, v interface{}) error
 	updatesTimeout  time.Duration
 	catchTimeout    time.Duration
+	methodTimeouts  map[string]time.Duration
 	DisablePatch    bool
+
+	failOnFatalLog     bool
+	autoMigrate        bool
+	floodWaitRetries   int
+	floodWaitMax       time.Duration
+	highWater          int
+	lowWater           int
+	fileUpdateThrottle time.Duration
+	deviceInfo         map[string]string
+	authKeyDropHandler func(notification *UpdateServiceNotification)
+	resyncOnReconnect  bool
+	heartbeatInterval  time.Duration
+	dropOnFull         bool
+	pendingUpdateTypes []Type
+	reconnectMax       time.Duration
+	requestSlots       chan struct{}
+	rateLimiter        *rateLimiter
+	sendPatchTimeout   time.Duration
+	sendSucceededDelay time.Duration
+	ignoreBacklogOlder time.Duration
+	backlogUpdating    bool
+	backlogDone        bool
+	receiveBackoff     time.Duration
+	receiveBackoffMax  time.Duration
+	receiveErrHandler  func(err error)
+	onReconnect        func(attempt int, err error)
+	authorizer         AuthorizationStateHandler
+	updatesTimedOut    chan struct{}
+	connectionReady    bool
+	wasReady           bool
+
+	done        chan struct{}
+	closed      chan struct{}
+	stopOnce    sync.Once
+	watchersMu  sync.Mutex
+	watchers    sync.WaitGroup
+	stopHooksMu sync.Mutex
+	stopHooks   []func()
+
+	startupMu     sync.Mutex
+	startupSize   int
+	startupBuffer []startupUpdate
+	startupDone   bool
+	// Limits total size of buffered responses if above zero.
+	startupMaxBytes int
+	startupBytes    int
+	// Listeners waiting for the receiver to replay the startup buffer to them, see replayStartup.
+	startupWaiting []*Listener
+	startupWake    chan struct{}
+	history        *updateHistory
+	rawHistory     *rawUpdateHistory
+
+	accentColorsMu sync.Mutex
+	accentColorIds []int32
+
+	idempotentMu    sync.Mutex
+	idempotentSends map[string]*idempotentSend
+
+	requestMwMu sync.RWMutex
+	requestMw   []func(req Request) Request
 }
 
 type Option func(*Client)
 
+// Name the client in pprof labels of its goroutines, TDLib client id is used by default.
+func WithName(name string) Option {
+	return func(client *Client) {
+		client.name = name
+	}
+}
+
+// Use custom JSON functions for requests sent by the client and its responses and updates, e.g. a faster JSON library
+// or a wrapper dumping raw JSON. Only @type and @client_id of responses received from TDLib are read by encoding/json,
+// since the package receiver finds the client by them. The codec decodes into generated types,
+// whose UnmarshalJSON methods decode fields of interface types by encoding/json.
+func WithJSONCodec(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) Option {
+	return func(client *Client) {
+		client.marshal = marshal
+		client.unmarshal = unmarshal
+	}
+}
+
+// unmarshalJSON decodes data into v by the unmarshal function of WithJSONCodec, if any.
+func (client *Client) unmarshalJSON(data []byte, v interface{}) error {
+	if client.unmarshal != nil {
+		return client.unmarshal(data, v)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// unmarshalType works like UnmarshalType, but decodes by the unmarshal function of WithJSONCodec, if any.
+func (client *Client) unmarshalType(data json.RawMessage) (Type, error) {
+	if client.unmarshal == nil {
+		return UnmarshalType(data)
+	}
+
+	var meta meta
+	err := client.unmarshal(data, &meta)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty object of the type is filled in by the codec.
+	typ, err := UnmarshalType(json.RawMessage(fmt.Sprintf(`{"@type":%q}`, meta.Type)))
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.unmarshal(data, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return typ, nil
+}
+
+// marshalJSON encodes v by the marshal function of WithJSONCodec, if any.
+func (client *Client) marshalJSON(v interface{}) ([]byte, error) {
+	if client.marshal != nil {
+		return client.marshal(v)
+	}
+
+	return json.Marshal(v)
+}
+
+// Use jsonClient instead of a TDLib client created by NewClient, e.g. a mock in tests.
+// Responses are received from jsonClient by the client itself, unless it's a *JsonClient,
+// which is received by the package receiver like any other TDLib client.
+func WithJsonClient(jsonClient Transport) Option {
+	return func(client *Client) {
+		client.transport = jsonClient
+	}
+}
+
 func WithExtraGenerator(extraGenerator ExtraGenerator) Option {
 	return func(client *Client) {
 		client.extraGenerator = extraGenerator
@@ -38,18 +190,187 @@ func WithCatchTimeout(timeout time.Duration) Option {
 	}
 }
 
+// Override catch timeout for particular methods, e.g. {"getChatHistory": 30 * time.Second}.
+func WithMethodTimeouts(timeouts map[string]time.Duration) Option {
+	return func(client *Client) {
+		client.methodTimeouts = timeouts
+	}
+}
+
 func WithProxy(req *AddProxyRequest) Option {
 	return func(client *Client) {
 		client.AddProxy(req)
 	}
 }
 
+// Abort NewClient with an error when TDLib reports a fatal error during authorization,
+// e.g. incompatible database, instead of hanging. Only clients being created at the moment get the error,
+// since TDLib doesn't tell which client the log message belongs to. TDLib still aborts the process
+// right after NewClient has returned the error, so it's meant for reporting the cause before exiting.
+// It replaces the TDLib log message callback of the process, which is set by td_set_log_message_callback.
+func WithFailOnFatalLog() Option {
+	return func(client *Client) {
+		client.failOnFatalLog = true
+	}
+}
+
+// Stop receiving from TDLib once more than highWater responses wait for processing, until they drain to lowWater.
+// Updates are kept by TDLib meanwhile. TDLib receiving is shared, so all clients of the process are paused.
+func WithReceiveBackpressure(highWater, lowWater int) Option {
+	return func(client *Client) {
+		client.highWater = highWater
+		client.lowWater = lowWater
+	}
+}
+
+// Resend requests failed because the basic group was upgraded to a supergroup to the new supergroup chat.
+func WithAutoMigrate() Option {
+	return func(client *Client) {
+		client.autoMigrate = true
+	}
+}
+
 func WithoutSendMessagePatch() Option {
 	return func(client *Client) {
 		client.DisablePatch = true
 	}
 }
 
+// Keep up to max updates of any type received before the first listener is added. Every buffered update
+// is replayed to the first listener accepting it, before any live update, updates it doesn't accept are kept
+// for listeners added later. Streams of helpers like OnFileUpdates don't take buffered updates.
+// The oldest updates are dropped when the buffer is full.
+func WithStartupBuffer(max int) Option {
+	return func(client *Client) {
+		client.startupSize = max
+	}
+}
+
+// Limit the startup buffer to maxBytes of update JSON in total, in addition to the number of updates,
+// see WithStartupBuffer.
+func WithStartupBufferBytes(maxBytes int) Option {
+	return func(client *Client) {
+		client.startupMaxBytes = maxBytes
+	}
+}
+
+// Emit at most one updateFile progress event per file in the interval from file update helpers.
+// Events of completed downloads and uploads are always emitted.
+func WithFileUpdateThrottle(interval time.Duration) Option {
+	return func(client *Client) {
+		client.fileUpdateThrottle = interval
+	}
+}
+
+// Override device model, system version and application version of setTdlibParameters request,
+// they are shown in the list of active sessions. Empty values are left untouched.
+func WithDeviceInfo(deviceModel, systemVersion, appVersion string) Option {
+	return func(client *Client) {
+		client.deviceInfo = map[string]string{}
+		for key, value := range map[string]string{
+			"device_model":        deviceModel,
+			"system_version":      systemVersion,
+			"application_version": appVersion,
+		} {
+			if value != "" {
+				client.deviceInfo[key] = value
+			}
+		}
+	}
+}
+
+// Called when Telegram asks to confirm the session by AUTH_KEY_DROP_ service notification.
+// Call client.Destroy() in fn to log out and remove all local data. Without the handler the notification
+// is only logged, since TDLib leaves the choice to the user. Stop waits for fn, so fn must not call Stop.
+func WithAuthKeyDropHandler(fn func(notification *UpdateServiceNotification)) Option {
+	return func(client *Client) {
+		client.authKeyDropHandler = fn
+	}
+}
+
+// Keep specific update types in memory until the first listener is added.
+func WithPendingUpdateType(update ...Type) Option {
+	return func(client *Client) {
+		client.pendingUpdateTypes = append(client.pendingUpdateTypes, update...)
+	}
+}
+
+// Wait between failed receives, starting with initial and doubling up to max until a response is received.
+// max below initial is raised to initial. Receive timeouts aren't failures, since TDLib receive blocks until
+// a response or timeout already. TDLib clients are received by one loop, which uses the longest backoff
+// of its clients.
+func WithReceiveBackoff(initial, max time.Duration) Option {
+	return func(client *Client) {
+		if max < initial {
+			max = initial
+		}
+		client.receiveBackoff = initial
+		client.receiveBackoffMax = max
+	}
+}
+
+// Called with errors of failed receives, see WithReceiveBackoff. An error of the loop receiving TDLib clients
+// is reported to all of them, since it isn't known which client it belongs to. fn must not block.
+func WithReceiveErrorHandler(fn func(err error)) Option {
+	return func(client *Client) {
+		client.receiveErrHandler = fn
+	}
+}
+
+// Text messages are usually sent within a second, media uploads take longer, see WithSendPatchTimeout.
+const defaultSendPatchTimeout = 1 * time.Second
+
+// Wait up to timeout for the message to be sent, so sendMessage returns it with the real id instead of
+// the temporary one, e.g. longer for media messages. The message with the temporary id is returned after timeout.
+// If sending fails meanwhile, the error is returned as ResponseError, Send returns the failed message with it.
+func WithSendPatchTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		client.sendPatchTimeout = timeout
+	}
+}
+
+// updateMessageSendSucceeded is delivered to listeners this much later than to sendMessage patch by default,
+// see WithSendSucceededDelay.
+const defaultSendSucceededDelay = 5 * time.Millisecond
+
+// Delay updateMessageSendSucceeded for listeners filtering it, so the patched sendMessage response
+// is returned first and the temporary id can be mapped to the real one in time.
+func WithSendSucceededDelay(delay time.Duration) Option {
+	return func(client *Client) {
+		client.sendSucceededDelay = delay
+	}
+}
+
+// Keep at most n requests waiting for response, the following ones wait for a free slot or their context.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(client *Client) {
+		if n > 0 {
+			client.requestSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// Drop updates for listeners not keeping up instead of blocking the client, counted by Listener.Dropped.
+func WithDropOnFull() Option {
+	return func(client *Client) {
+		client.dropOnFull = true
+	}
+}
+
+// Close UpdatesTimedOut channel once nothing is received from TDLib for longer than timeout.
+func WithUpdatesTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		client.updatesTimeout = timeout
+	}
+}
+
+// Reload the main chat list when connection state becomes ready again after a disconnect.
+func WithResyncOnReconnect() Option {
+	return func(client *Client) {
+		client.resyncOnReconnect = true
+	}
+}
+
 func SetLogLevel(level int32) {
 	_, _ = SetLogVerbosityLevel(&SetLogVerbosityLevelRequest{
 		NewVerbosityLevel: level,
@@ -67,6 +388,9 @@ func SetFilePath(path string) {
 }
 
 // Keep specific update type in memory when listener is not ready.
+// Applies to clients created afterwards without WithPendingUpdateType.
+//
+// Deprecated: use WithPendingUpdateType, this one is shared by all clients.
 func SetPendingUpdateType(update ...Type) {
 	for _, v := range update {
 		pendingUpdateType = append(pendingUpdateType, v)
@@ -74,35 +398,117 @@ func SetPendingUpdateType(update ...Type) {
 }
 
 func NewClient(authorizationStateHandler AuthorizationStateHandler, options ...Option) (*Client, error) {
+	client := newClient(options, func(client *Client) Transport {
+		jsonClient := NewJsonClient()
+		if client.marshal != nil {
+			jsonClient.marshal = client.marshal
+			jsonClient.unmarshal = client.unmarshal
+		}
+		return jsonClient
+	}, "injected")
+
+	var fatal <-chan string
+	if client.failOnFatalLog {
+		var stopWatching func()
+		fatal, stopWatching = watchFatalLog()
+		defer stopWatching()
+	}
+
+	client.start()
+
+	if client.reconnectMax > 0 {
+		// The handler is run again on reconnect, so it must not be closed.
+		authorizationStateHandler = reusableAuthorizer{authorizationStateHandler}
+		client.authorizer = authorizationStateHandler
+	}
+
+	authorized := make(chan error, 1)
+	go func() {
+		authorized <- Authorize(client, authorizationStateHandler)
+	}()
+
+	select {
+	case err := <-authorized:
+		if err != nil {
+			client.Stop()
+			return nil, err
+		}
+	case message := <-fatal:
+		client.Stop()
+		return nil, fmt.Errorf("tdlib fatal error: %s", message)
+	}
+	atomic.StoreInt32(&client.authorized, 1)
+
+	return client, nil
+}
+
+// newClient creates the client of NewClient and NewTestClient with the options applied. Its transport is made
+// by newTransport unless WithJsonClient is passed, the client is named by fallbackName if the transport
+// isn't a JsonClient and WithName isn't passed.
+func newClient(options []Option, newTransport func(client *Client) Transport, fallbackName string) *Client {
 	client := &Client{
-		jsonClient:      NewJsonClient(),
 		responses:       make(chan *Response, 1000),
 		pendingResp:     make(chan *Response, 1000),
 		listenerStore:   newListenerStore(),
 		catchersStore:   &sync.Map{},
 		successMsgStore: &sync.Map{},
+		done:            make(chan struct{}),
+		closed:          make(chan struct{}),
+		updatesTimedOut: make(chan struct{}),
+		startupWake:     make(chan struct{}, 1),
 	}
 
 	client.extraGenerator = UuidV4Generator()
 	client.catchTimeout = 60 * time.Second
+	client.sendPatchTimeout = defaultSendPatchTimeout
+	client.sendSucceededDelay = defaultSendSucceededDelay
 
 	for _, option := range options {
 		option(client)
 	}
 
-	tdlibInstance.addClient(client)
+	if client.transport == nil {
+		client.transport = newTransport(client)
+	}
+	if jsonClient, ok := client.transport.(*JsonClient); ok {
+		client.jsonClient = jsonClient
+	}
+
+	if client.name == "" {
+		if client.jsonClient != nil {
+			client.name = strconv.Itoa(client.jsonClient.id)
+		} else {
+			client.name = fallbackName
+		}
+	}
+	if client.pendingUpdateTypes == nil {
+		client.pendingUpdateTypes = append([]Type{}, pendingUpdateType...)
+	}
 
-	go client.processPendingResponse()
-	go client.receiver()
+	return client
+}
 
-	err := Authorize(client, authorizationStateHandler)
-	if err != nil {
-		return nil, err
+// start receives responses of the client and starts its background goroutines.
+func (client *Client) start() {
+	if client.jsonClient != nil {
+		// Receiving it separately would steal responses of other TDLib clients.
+		tdlibInstance.addClient(client)
+	} else {
+		client.goLabeled("transport", client.receiveTransport)
 	}
 
-	return client, nil
+	client.goLabeled("pending", client.processPendingResponse)
+	client.goLabeled("receiver", client.receiver)
+	if client.heartbeatInterval > 0 {
+		client.goLabeled("heartbeat", client.heartbeat)
+	}
+	if client.updatesTimeout > 0 {
+		client.goLabeled("watchdog", client.watchUpdates)
+	}
 }
 
+// processResponse handles the response received from TDLib. Called from the receiver goroutine only,
+// so the handlers below keep their state without locking.
 func (client *Client) processResponse(response *Response) {
 	if response.Extra != "" {
 		value, ok := client.catchersStore.Load(response.Extra)
@@ -111,43 +517,109 @@ func (client *Client) processResponse(response *Response) {
 		}
 	}
 
-	typ, err := UnmarshalType(response.Data)
+	if response.Type == TypeUpdateHeartbeat {
+		heartbeat, err := unmarshalHeartbeat(response.Data)
+		if err == nil {
+			client.dispatch(response, heartbeat)
+		}
+		return
+	}
+
+	// Heartbeats aren't received from TDLib, so they don't count.
+	atomic.StoreInt64(&client.lastUpdate, time.Now().UnixNano())
+
+	typ, err := client.unmarshalType(response.Data)
 	if err != nil {
 		return
 	}
 
-	if !client.DisablePatch && typ.GetType() == (&UpdateMessageSendSucceeded{}).GetType() {
-		sendVal, sOk := client.successMsgStore.Load(typ.(*UpdateMessageSendSucceeded).OldMessageId)
-		if sOk {
-			sendVal.(chan *Response) <- response
+	if !client.DisablePatch {
+		var oldMessageId int64
+		switch upd := typ.(type) {
+		case *UpdateMessageSendSucceeded:
+			oldMessageId = upd.OldMessageId
+		case *UpdateMessageSendFailed:
+			oldMessageId = upd.OldMessageId
+		}
+
+		if oldMessageId != 0 {
+			sendVal, sOk := client.successMsgStore.Load(oldMessageId)
+			if sOk {
+				sendVal.(chan *Response) <- response
+			}
 		}
 	}
 
-	if len(client.listenerStore.Listeners()) == 0 {
-		for _, p := range pendingUpdateType {
+	if notification, ok := typ.(*UpdateServiceNotification); ok && isAuthKeyDrop(notification) {
+		client.handleAuthKeyDrop(notification)
+	}
+
+	if colors, ok := typ.(*UpdateAccentColors); ok {
+		client.setAccentColors(colors)
+	}
+
+	if state, ok := typ.(*UpdateAuthorizationState); ok && client.reconnectMax > 0 {
+		client.handleAuthorizationState(state)
+	}
+
+	if state, ok := typ.(*UpdateConnectionState); ok && client.resyncOnReconnect {
+		client.handleConnectionState(state)
+	}
+
+	if client.ignoreBacklogOlder > 0 && client.isOldBacklog(typ) {
+		return
+	}
+
+	seq := client.dispatch(response, typ)
+	if response.Extra == "" {
+		client.bufferStartup(response, seq)
+	}
+}
+
+// dispatch delivers the update to listeners and returns its sequence number.
+func (client *Client) dispatch(response *Response, typ Type) uint64 {
+	if client.history != nil {
+		client.history.Add(typ)
+	}
+	if client.rawHistory != nil {
+		client.rawHistory.Add(response.Data)
+	}
+
+	// The startup buffer keeps updates of any type already.
+	if client.startupSize <= 0 && len(client.listenerStore.Listeners()) == 0 {
+		for _, p := range client.pendingUpdateTypes {
 			if typ.GetType() == p.GetType() {
 				client.pendingResp <- response
 			}
 		}
 	}
 
+	seq := atomic.AddUint64(&client.updateSeq, 1)
+
 	needGc := false
 	for _, listener := range client.listenerStore.Listeners() {
-		if listener.IsActive() && listener.Updates != nil && typ.GetType() == listener.Filter.GetType() { // All updates go to Updates channel if type == filter
+		if listener.IsActive() && listener.Updates != nil && listener.matches(typ) { // All updates go to Updates channel if type == filter
 			// Make some delay to UpdateMessageSendSucceeded listener
 			// This can make UpdateMessageSendSucceeded response later than sendMessage response.
 			// This may help a bot developer to map temporary message id to actual message id easily.
 			// Cause an event listener slower than sendMessage response, so you have enough time to do mapping stuff.
 			if typ.GetType() == (&UpdateMessageSendSucceeded{}).GetType() {
 				go func(listener *Listener, typ Type) {
-					time.Sleep(5 * time.Millisecond)
-					listener.Updates <- typ
+					time.Sleep(client.sendSucceededDelay)
+					listener.deliver(listener.Updates, typ)
 				}(listener, typ)
 			} else {
-				listener.Updates <- typ
+				listener.deliver(listener.Updates, typ)
 			}
 		} else if listener.IsActive() && listener.RawUpdates != nil { // All updates go to RawUpdates channel if filter is empty
-			listener.RawUpdates <- typ
+			listener.deliver(listener.RawUpdates, typ)
+		} else if listener.IsActive() && listener.SeqUpdates != nil { // Numbered updates go to SeqUpdates channel if type == filter or filter is empty
+			if listener.unfiltered() || listener.matches(typ) {
+				listener.deliverSeq(SeqUpdate{
+					Seq:    seq,
+					Update: typ,
+				})
+			}
 		} else if !listener.IsActive() { // GC inactive listener
 			needGc = true
 		}
@@ -155,17 +627,167 @@ func (client *Client) processResponse(response *Response) {
 	if needGc {
 		client.listenerStore.gc()
 	}
+
+	return seq
+}
+
+// startupUpdate keeps the raw update only, it's decoded again on replay, so WithStartupBufferBytes bounds the memory.
+type startupUpdate struct {
+	response *Response
+	seq      uint64
+}
+
+// bufferStartup keeps the update in the startup buffer if no listener has been added yet.
+// Called from the receiver goroutine only.
+func (client *Client) bufferStartup(response *Response, seq uint64) {
+	client.startupMu.Lock()
+	defer client.startupMu.Unlock()
+
+	if client.startupSize <= 0 || client.startupDone {
+		return
+	}
+
+	if len(client.startupBuffer) >= client.startupSize {
+		client.startupBytes -= len(client.startupBuffer[0].response.Data)
+		client.startupBuffer = client.startupBuffer[1:]
+	}
+	client.startupBuffer = append(client.startupBuffer, startupUpdate{
+		response: response,
+		seq:      seq,
+	})
+	client.startupBytes += len(response.Data)
+
+	for client.startupMaxBytes > 0 && client.startupBytes > client.startupMaxBytes && len(client.startupBuffer) > 0 {
+		client.startupBytes -= len(client.startupBuffer[0].response.Data)
+		client.startupBuffer = client.startupBuffer[1:]
+	}
+}
+
+// replayStartup delivers the startup buffer to waiting listeners in the order they were added, then adds them
+// to the store. Every update goes to the first listener accepting it, the rest is kept for the next one.
+// Called from the receiver goroutine, so live updates are dispatched after the replay.
+func (client *Client) replayStartup() {
+	client.startupMu.Lock()
+	waiting := append([]*Listener{}, client.startupWaiting...)
+	client.startupDone = true
+	client.startupMu.Unlock()
+
+	for _, listener := range waiting {
+		// Only the receiver changes the buffer once startupDone is set.
+		client.startupMu.Lock()
+		buffered := client.startupBuffer
+		client.startupMu.Unlock()
+
+		var kept []startupUpdate
+		keptBytes := 0
+		for _, update := range buffered {
+			typ, err := client.unmarshalType(update.response.Data)
+			if err != nil {
+				continue
+			}
+			if client.IsRunning() && listener.accepts(typ) {
+				listener.deliverUpdate(typ, update.seq)
+				continue
+			}
+			kept = append(kept, update)
+			keptBytes += len(update.response.Data)
+		}
+
+		client.startupMu.Lock()
+		client.startupBuffer = kept
+		client.startupBytes = keptBytes
+		client.startupWaiting = removeListener(client.startupWaiting, listener)
+		client.startupMu.Unlock()
+
+		client.listenerStore.Add(listener)
+		if !client.IsRunning() {
+			listener.Close()
+		}
+	}
+}
+
+// waitStartupReplay queues the listener for replayStartup while the startup buffer is in use.
+func (client *Client) waitStartupReplay(listener *Listener) bool {
+	client.startupMu.Lock()
+	defer client.startupMu.Unlock()
+
+	if listener.internal || client.startupSize <= 0 || !client.IsRunning() {
+		return false
+	}
+	if client.startupDone && len(client.startupBuffer) == 0 && len(client.startupWaiting) == 0 {
+		return false
+	}
+
+	if listener.done == nil {
+		listener.done = make(chan struct{})
+	}
+	client.startupWaiting = append(client.startupWaiting, listener)
+
+	select {
+	case client.startupWake <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+func (client *Client) addListener(listener *Listener) {
+	listener.dropOnFull = client.dropOnFull
+	if client.waitStartupReplay(listener) {
+		return
+	}
+
+	client.listenerStore.Add(listener)
+
+	// Checked after adding, so either the check or Stop closes the listener.
+	if !client.IsRunning() {
+		listener.Close()
+	}
+}
+
+// How often the responses queue is checked while receiving is paused.
+const backpressurePollInterval = 10 * time.Millisecond
+
+// waitBackpressure blocks while the responses queue is over the high water mark, see WithReceiveBackpressure.
+func (client *Client) waitBackpressure() {
+	if client.highWater <= 0 || len(client.responses) < client.highWater {
+		return
+	}
+
+	for len(client.responses) > client.lowWater {
+		select {
+		case <-client.done:
+			return
+		case <-time.After(backpressurePollInterval):
+		}
+	}
+}
+
+// goLabeled starts fn with client name and role pprof labels, so goroutines of different clients
+// can be told apart in profiles.
+func (client *Client) goLabeled(role string, fn func()) {
+	labels := pprof.Labels("tdlib_client", client.name, "role", role)
+	go pprof.Do(context.Background(), labels, func(context.Context) {
+		fn()
+	})
 }
 
 func (client *Client) receiver() {
-	for response := range client.responses {
-		client.processResponse(response)
+	for {
+		select {
+		case response := <-client.responses:
+			client.processResponse(response)
+		case <-client.startupWake:
+			client.replayStartup()
+		case <-client.closed:
+			return
+		}
 	}
 }
 
 func (client *Client) processPendingResponse() {
 	// No need to process pending response if no pending list.
-	if len(pendingUpdateType) == 0 {
+	if len(client.pendingUpdateTypes) == 0 {
 		return
 	}
 
@@ -174,90 +796,488 @@ func (client *Client) processPendingResponse() {
 		if len(client.listenerStore.Listeners()) > 0 {
 			break
 		}
-		time.Sleep(1 * time.Second)
+		select {
+		case <-time.After(1 * time.Second):
+		case <-client.closed:
+			return
+		}
 	}
 
 	// Start processing pending response
-	for response := range client.pendingResp {
-		client.processResponse(response)
+	for {
+		select {
+		case response := <-client.pendingResp:
+			// The response has been processed by the receiver already, it just wasn't delivered.
+			typ, err := client.unmarshalType(response.Data)
+			if err != nil {
+				continue
+			}
+			client.dispatch(response, typ)
+		case <-client.closed:
+			return
+		}
 	}
 }
 
+// Send sends the request and waits for its response. TDLib error response is returned along with ResponseError,
+// use errors.As with *Error to get its code and message.
 func (client *Client) Send(req Request) (*Response, error) {
+	return client.SendContext(context.Background(), req)
+}
+
+// SendContext works like Send, but gives up waiting for the response with ctx.Err() once ctx is done.
+// The catch timeout still applies if ctx has a later or no deadline.
+func (client *Client) SendContext(ctx context.Context, req Request) (*Response, error) {
 	req.Extra = client.extraGenerator()
 
+	return client.send(ctx, req)
+}
+
+// Separates trace id from the generated part of @extra.
+const traceSeparator = "|"
+
+// SendWithTrace sends the request with traceId prepended to its @extra, so the request can be found
+// in TDLib logs and the trace id can be read back from the response by TraceId.
+func (client *Client) SendWithTrace(ctx context.Context, req Request, traceId string) (*Response, error) {
+	req.Extra = traceId + traceSeparator + client.extraGenerator()
+
+	return client.send(ctx, req)
+}
+
+// TraceId returns trace id of the response to a request sent by SendWithTrace.
+func TraceId(response *Response) (string, bool) {
+	i := strings.Index(response.Extra, traceSeparator)
+	if i == -1 {
+		return "", false
+	}
+
+	return response.Extra[:i], true
+}
+
+var ErrRequestBlocked = errors.New("request is blocked")
+
+// UseRequest adds a middleware called for every request before it is sent, in the order they were added.
+// It may return a modified request or BlockRequest(req, reason) to reject the request without sending it.
+func (client *Client) UseRequest(mw func(req Request) Request) {
+	client.requestMwMu.Lock()
+	defer client.requestMwMu.Unlock()
+
+	client.requestMw = append(client.requestMw, mw)
+}
+
+// BlockRequest marks the request returned by UseRequest middleware as rejected,
+// Send returns ErrRequestBlocked with the reason.
+func BlockRequest(req Request, reason string) Request {
+	req.blocked = fmt.Errorf("%s: %w: %s", req.Type, ErrRequestBlocked, reason)
+
+	return req
+}
+
+func (client *Client) applyRequestMiddlewares(req Request) Request {
+	client.requestMwMu.RLock()
+	defer client.requestMwMu.RUnlock()
+
+	for _, mw := range client.requestMw {
+		req = mw(req)
+		if req.blocked != nil {
+			break
+		}
+	}
+
+	return req
+}
+
+func (client *Client) send(ctx context.Context, req Request) (*Response, error) {
+	req = client.applyRequestMiddlewares(req)
+	if req.blocked != nil {
+		return nil, req.blocked
+	}
+
+	if key, ok := ctx.Value(idempotencyKeyContext{}).(string); ok && req.Type == "sendMessage" {
+		return client.sendIdempotent(ctx, key, req)
+	}
+
+	started, err := client.startRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.finishSend(ctx, req, started)
+}
+
+// finishSend waits for the response of the started request, retrying and migrating it like send does.
+func (client *Client) finishSend(ctx context.Context, req Request, started *startedRequest) (*Response, error) {
+	response, err := client.awaitResponse(ctx, started)
+	if err == nil && client.floodWaitRetries > 0 {
+		response, err = client.retryFloodWait(ctx, req, response)
+	}
+	if err == nil && client.autoMigrate && response.Type == "error" {
+		if migrated, ok := client.migrateRequest(req, response); ok {
+			response, err = client.sendOnce(ctx, migrated)
+		}
+	}
+
+	// The response is still returned for callers checking its type.
+	if err == nil && response.Type == "error" {
+		return response, buildResponseError(response.Data)
+	}
+
+	return response, err
+}
+
+func (client *Client) sendOnce(ctx context.Context, req Request) (*Response, error) {
+	started, err := client.startRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.awaitResponse(ctx, started)
+}
+
+// startedRequest is a request sent to TDLib, which waits for its response in catcher.
+type startedRequest struct {
+	req     Request
+	catcher chan *Response
+	release func()
+	// How long to wait for the response and for the sendMessage patch.
+	catchTimeout time.Duration
+	patchTimeout time.Duration
+}
+
+// requestTimeout returns the catch timeout of requests of the type, see WithMethodTimeouts.
+func (client *Client) requestTimeout(typ string) time.Duration {
+	if methodTimeout, ok := client.methodTimeouts[typ]; ok {
+		return methodTimeout
+	}
+
+	return client.catchTimeout
+}
+
+// startRequest registers the catcher of the request and sends it, the response is waited for by awaitResponse.
+func (client *Client) startRequest(ctx context.Context, req Request) (*startedRequest, error) {
+	if req.Type == "setTdlibParameters" {
+		for key, value := range client.deviceInfo {
+			req.Data[key] = value
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case <-client.closed:
+		return nil, ErrClientStopped
+	default:
+	}
+
+	if client.rateLimiter != nil {
+		if err := client.rateLimiter.Wait(ctx, client.closed, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if client.requestSlots != nil {
+		select {
+		case client.requestSlots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-client.closed:
+			return nil, ErrClientStopped
+		}
+	}
+
+	// Catchers are buffered and never closed, since a late response may still be delivered
+	// to a catcher of the cancelled request.
 	catcher := make(chan *Response, 1)
 
 	client.catchersStore.Store(req.Extra, catcher)
+	atomic.AddInt64(&client.pendingRequests, 1)
 
-	defer func() {
-		client.catchersStore.Delete(req.Extra)
-		close(catcher)
-	}()
+	client.currentTransport().Send(req)
+
+	return &startedRequest{
+		req:          req,
+		catcher:      catcher,
+		catchTimeout: client.requestTimeout(req.Type),
+		patchTimeout: client.sendPatchTimeout,
+		release: func() {
+			client.catchersStore.Delete(req.Extra)
+			atomic.AddInt64(&client.pendingRequests, -1)
+			if client.requestSlots != nil {
+				<-client.requestSlots
+			}
+		},
+	}, nil
+}
+
+// awaitResponse waits for the response of the started request up to its catch timeout, then releases the request.
+func (client *Client) awaitResponse(ctx context.Context, started *startedRequest) (*Response, error) {
+	defer started.release()
 
-	client.jsonClient.Send(req)
+	req := started.req
+	catcher := started.catcher
 
-	ctx, cancel := context.WithTimeout(context.Background(), client.catchTimeout)
+	timeout, cancel := context.WithTimeout(ctx, started.catchTimeout)
 	defer cancel()
 
 	select {
 	case response := <-catcher:
 		if !client.DisablePatch && response.Type != "error" && req.Type == "sendMessage" {
-			m, err := UnmarshalMessage(response.Data)
+			m := &Message{}
+			err := client.unmarshalJSON(response.Data, m)
 			if err != nil {
 				return nil, err
 			}
 
-			if m.Content.MessageContentType() == "messageText" || m.Content.MessageContentType() == "messageDice" {
+			// Any content sent through the pending state is replaced by updateMessageSendSucceeded.
+			if _, pending := m.SendingState.(*MessageSendingStatePending); pending {
 				successCatcher := make(chan *Response, 1)
 				client.successMsgStore.Store(m.Id, successCatcher)
 
-				defer (func() {
-					client.successMsgStore.Delete(m.Id)
-					close(successCatcher)
-				})()
+				defer client.successMsgStore.Delete(m.Id)
 
 				select {
 				case modResponse := <-successCatcher:
-					m2, err2 := UnmarshalUpdateMessageSendSucceeded(modResponse.Data)
+					m2, err2 := client.unmarshalType(modResponse.Data)
 					if err2 != nil {
 						return response, nil
 					}
-					response.Data = bytes.Replace(response.Data, []byte("\"@type\":\"messageSendingStatePending\""), []byte("\"@type\":\"updateMessageSendSucceeded\""), 1)
-					response.Data = bytes.Replace(response.Data, []byte("\"id\":"+strconv.FormatInt(m.Id, 10)), []byte("\"id\":"+strconv.FormatInt(m2.Message.Id, 10)), 1)
+
+					var sent *Message
+					var sendErr error
+					switch upd := m2.(type) {
+					case *UpdateMessageSendSucceeded:
+						// The sent message has the real id and no sending state.
+						sent = upd.Message
+					case *UpdateMessageSendFailed:
+						// The failed message is returned along with the error, for callers of Send checking it.
+						sent = upd.Message
+						sendErr = errors.New("message sending failed")
+						if upd.Error != nil {
+							sendErr = ResponseError{Err: upd.Error}
+						}
+					default:
+						return response, nil
+					}
+
+					data, err2 := client.marshalJSON(sent)
+					if err2 != nil {
+						return response, sendErr
+					}
+					response.Data = data
+					return response, sendErr
+				case <-time.After(started.patchTimeout):
 					return response, nil
-				case <-time.After(1 * time.Second):
+				case <-ctx.Done():
+					// The message is sent already, so return it with the temporary id.
+					return response, nil
+				case <-client.closed:
 					return response, nil
 				}
 			}
 		}
 		return response, nil
-	case <-ctx.Done():
+	case <-timeout.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("response catching timeout")
+	case <-client.closed:
+		return nil, ErrClientStopped
+	}
+}
+
+// LastUpdateTime returns when the last update or response was received, zero time if none yet.
+func (client *Client) LastUpdateTime() time.Time {
+	last := atomic.LoadInt64(&client.lastUpdate)
+	if last == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, last)
+}
+
+// UpdatesTimedOut returns a channel closed once nothing is received for longer than WithUpdatesTimeout,
+// e.g. to recreate the client. The channel is never closed without the option.
+func (client *Client) UpdatesTimedOut() <-chan struct{} {
+	return client.updatesTimedOut
+}
+
+func (client *Client) watchUpdates() {
+	// Count from the client start until the first update.
+	started := time.Now()
+
+	for {
+		last := client.LastUpdateTime()
+		if last.IsZero() {
+			last = started
+		}
+
+		wait := client.updatesTimeout - time.Since(last)
+		if wait <= 0 {
+			close(client.updatesTimedOut)
+			return
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-client.done:
+			return
+		}
 	}
 }
 
+// PendingRequests returns the number of requests waiting for TDLib response.
+// A growing number means TDLib doesn't keep up with requests.
+func (client *Client) PendingRequests() int {
+	return int(atomic.LoadInt64(&client.pendingRequests))
+}
+
+// IsRunning reports whether the client isn't stopped yet.
+func (client *Client) IsRunning() bool {
+	select {
+	case <-client.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// GetListener returns a listener receiving all updates. Listeners of a stopped client are returned inactive,
+// with their channels closed, see IsRunning.
 func (client *Client) GetListener() *Listener {
 	listener := &Listener{
 		isActive:   true,
 		RawUpdates: make(chan Type, 1000),
 	}
-	client.listenerStore.Add(listener)
+	client.addListener(listener)
 
 	return listener
 }
 
+// AddEventReceiver returns a listener receiving updates of msgType. Like GetListener, it returns an inactive
+// listener with closed channels if the client is stopped.
 func (client *Client) AddEventReceiver(msgType Type, channelCapacity int) *Listener {
 	listener := &Listener{
 		isActive: true,
 		Updates:  make(chan Type, channelCapacity),
 		Filter:   msgType,
 	}
-	client.listenerStore.Add(listener)
+	client.addListener(listener)
+
+	return listener
+}
+
+// AddEventReceiverForTypes works like AddEventReceiver, but delivers updates of any of the given types to one channel.
+func (client *Client) AddEventReceiverForTypes(channelCapacity int, types ...Type) *Listener {
+	listener := &Listener{
+		isActive: true,
+		Updates:  make(chan Type, channelCapacity),
+		types:    map[string]struct{}{},
+	}
+	for _, typ := range types {
+		listener.types[typ.GetType()] = struct{}{}
+	}
+	client.addListener(listener)
+
+	return listener
+}
+
+// AddEventReceiverSeq works like AddEventReceiver, but every update is numbered in the order it was received,
+// so updates of several listeners can be merged back in order. Pass nil msgType to receive all updates.
+func (client *Client) AddEventReceiverSeq(msgType Type, channelCapacity int) *Listener {
+	listener := &Listener{
+		isActive:   true,
+		SeqUpdates: make(chan SeqUpdate, channelCapacity),
+		Filter:     msgType,
+	}
+	client.addListener(listener)
 
 	return listener
 }
 
+// JsonClient returns underlying TDLib client for low-level integrations, nil if WithJsonClient is used
+// with another Transport.
+func (client *Client) JsonClient() *JsonClient {
+	client.transportMu.RLock()
+	defer client.transportMu.RUnlock()
+
+	return client.jsonClient
+}
+
+func (client *Client) currentTransport() Transport {
+	client.transportMu.RLock()
+	defer client.transportMu.RUnlock()
+
+	return client.transport
+}
+
+// OnStop registers fn to be called by Stop after helpers watching updates have exited
+// and before the client is destroyed. Hooks are called in registration order, a panic in a hook is logged.
+func (client *Client) OnStop(fn func()) {
+	client.stopHooksMu.Lock()
+	defer client.stopHooksMu.Unlock()
+
+	client.stopHooks = append(client.stopHooks, fn)
+}
+
+func (client *Client) runStopHooks() {
+	client.stopHooksMu.Lock()
+	hooks := client.stopHooks
+	client.stopHooksMu.Unlock()
+
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("stop hook panic: %v", r)
+				}
+			}()
+			hook()
+		}()
+	}
+}
+
+// Stop destroys the client, helpers watching updates are stopped as well.
+// Once stop hooks are called, requests waiting for response fail with ErrClientStopped, listeners are closed
+// and the client stops receiving. It's safe to call Stop more than once.
 func (client *Client) Stop() {
-	client.Destroy()
+	stopped := false
+	client.stopOnce.Do(func() {
+		// no watcher is added once done is closed, so Wait below can't race with subscribe
+		client.watchersMu.Lock()
+		close(client.done)
+		client.watchersMu.Unlock()
+		stopped = true
+	})
+	if !stopped {
+		return
+	}
+
+	client.watchers.Wait()
+	client.runStopHooks()
+
+	close(client.closed)
+	for _, listener := range client.listenerStore.Listeners() {
+		listener.Close()
+	}
+	client.startupMu.Lock()
+	waiting := client.startupWaiting
+	client.startupMu.Unlock()
+	for _, listener := range waiting {
+		listener.Close()
+	}
+
+	// Nobody receives the response anymore, so don't wait for it.
+	client.currentTransport().Send(Request{
+		meta: meta{
+			Type:  "destroy",
+			Extra: client.extraGenerator(),
+		},
+		Data: map[string]interface{}{},
+	})
+
+	if jsonClient := client.JsonClient(); jsonClient != nil {
+		tdlibInstance.removeClient(jsonClient.id)
+	}
 }
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func feed(t *testing.T, client *Client, updates ...string) {
+	t.Helper()
+
+	for _, update := range updates {
+		if err := client.FeedUpdate([]byte(update)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func chatTitleUpdate(chatId int64) string {
+	return fmt.Sprintf(`{"@type":"updateChatTitle","chat_id":%d,"title":"title"}`, chatId)
+}
+
+func chatReadUpdate(chatId int64) string {
+	return fmt.Sprintf(`{"@type":"updateChatIsMarkedAsUnread","chat_id":%d,"is_marked_as_unread":true}`, chatId)
+}
+
+// waitBuffered waits until the receiver has buffered n startup updates.
+func waitBuffered(t *testing.T, client *Client, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		client.startupMu.Lock()
+		buffered := len(client.startupBuffer)
+		client.startupMu.Unlock()
+		if buffered == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d updates aren't buffered", n)
+}
+
+func receive(t *testing.T, updates chan Type) Type {
+	t.Helper()
+
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+		return nil
+	}
+}
+
+func TestStartupBufferReplaysToMatchingListener(t *testing.T) {
+	client := NewTestClient(WithStartupBuffer(10))
+	defer client.Stop()
+
+	// a helper stream must neither take the buffer nor miss live updates
+	files, stopFiles := client.OnFileUpdates(10)
+	defer stopFiles()
+
+	feed(t, client, chatTitleUpdate(1), chatReadUpdate(2), chatTitleUpdate(3))
+	waitBuffered(t, client, 3)
+
+	read := client.AddEventReceiver(&UpdateChatIsMarkedAsUnread{}, 10)
+	if upd := receive(t, read.Updates).(*UpdateChatIsMarkedAsUnread); upd.ChatId != 2 {
+		t.Fatalf("unexpected chat %d", upd.ChatId)
+	}
+
+	titles := client.AddEventReceiver(&UpdateChatTitle{}, 10)
+	feed(t, client, chatTitleUpdate(4))
+	for _, chatId := range []int64{1, 3, 4} {
+		if upd := receive(t, titles.Updates).(*UpdateChatTitle); upd.ChatId != chatId {
+			t.Fatalf("expected chat %d, got %d", chatId, upd.ChatId)
+		}
+	}
+
+	feed(t, client, `{"@type":"updateFile","file":{"@type":"file","id":5,"local":{"@type":"localFile"},"remote":{"@type":"remoteFile"}}}`)
+	select {
+	case file := <-files:
+		if file.Id != 5 {
+			t.Fatalf("unexpected file %d", file.Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("helper stream missed live update")
+	}
+}
+
+func TestStartupBufferSeqOrder(t *testing.T) {
+	client := NewTestClient(WithStartupBuffer(10))
+	defer client.Stop()
+
+	feed(t, client, chatTitleUpdate(1), chatTitleUpdate(2))
+	waitBuffered(t, client, 2)
+
+	listener := client.AddEventReceiverSeq(nil, 10)
+	feed(t, client, chatTitleUpdate(3))
+
+	var last uint64
+	for _, chatId := range []int64{1, 2, 3} {
+		var update SeqUpdate
+		select {
+		case update = <-listener.SeqUpdates:
+		case <-time.After(time.Second):
+			t.Fatal("no update received")
+		}
+		if update.Seq <= last {
+			t.Fatalf("sequence %d after %d", update.Seq, last)
+		}
+		last = update.Seq
+		if upd := update.Update.(*UpdateChatTitle); upd.ChatId != chatId {
+			t.Fatalf("expected chat %d, got %d", chatId, upd.ChatId)
+		}
+	}
+}
+
+func TestJSONCodecDecodesUpdates(t *testing.T) {
+	var decoded int32
+	client := NewTestClient(WithJSONCodec(json.Marshal, func(data []byte, v interface{}) error {
+		if _, ok := v.(*UpdateChatTitle); ok {
+			atomic.AddInt32(&decoded, 1)
+		}
+		return json.Unmarshal(data, v)
+	}))
+	defer client.Stop()
+
+	titles := client.AddEventReceiver(&UpdateChatTitle{}, 10)
+	feed(t, client, chatTitleUpdate(1))
+	if upd := receive(t, titles.Updates).(*UpdateChatTitle); upd.ChatId != 1 || upd.Title != "title" {
+		t.Fatalf("unexpected update %+v", upd)
+	}
+
+	if atomic.LoadInt32(&decoded) != 1 {
+		t.Fatal("update isn't decoded by the client codec")
+	}
+}
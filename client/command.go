@@ -0,0 +1,96 @@
+package client
+
+import (
+	"strings"
+	"sync"
+)
+
+// CommandContext is passed to CommandRouter handlers.
+type CommandContext struct {
+	ChatId  int64
+	Message *Message
+	// Command without "@botname" mention, e.g. "/start"
+	Command string
+	// Text after the command, see CommandArgument
+	Argument string
+	// Argument split by whitespace, see CommandArgs
+	Args []string
+}
+
+// CommandRouter dispatches bot commands of incoming messages to handlers registered by Handle.
+type CommandRouter struct {
+	mu       sync.RWMutex
+	handlers map[string]func(ctx *CommandContext)
+	fallback func(ctx *CommandContext)
+}
+
+func NewCommandRouter() *CommandRouter {
+	return &CommandRouter{
+		handlers: map[string]func(ctx *CommandContext){},
+	}
+}
+
+// Handle registers fn for the command, with or without leading "/", e.g. "start".
+func (router *CommandRouter) Handle(command string, fn func(ctx *CommandContext)) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	if !strings.HasPrefix(command, "/") {
+		command = "/" + command
+	}
+	router.handlers[command] = fn
+}
+
+// Default registers fn for commands without a handler.
+func (router *CommandRouter) Default(fn func(ctx *CommandContext)) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	router.fallback = fn
+}
+
+// HandleUpdate dispatches the command from updateNewMessage, other updates are ignored.
+// Returns true if a handler has been called.
+func (router *CommandRouter) HandleUpdate(update Type) bool {
+	upd, ok := update.(*UpdateNewMessage)
+	if !ok {
+		return false
+	}
+
+	return router.HandleMessage(upd.Message)
+}
+
+// HandleMessage dispatches the command from the text message. Returns true if a handler has been called.
+func (router *CommandRouter) HandleMessage(message *Message) bool {
+	content, ok := message.Content.(*MessageText)
+	if !ok {
+		return false
+	}
+
+	text := content.Text.Text
+	command := CheckCommand(text, content.Text.Entities)
+	if command == "" {
+		return false
+	}
+
+	router.mu.RLock()
+	fn, ok := router.handlers[command]
+	if !ok {
+		fn = router.fallback
+	}
+	router.mu.RUnlock()
+
+	if fn == nil {
+		return false
+	}
+
+	fn(&CommandContext{
+		ChatId:   message.ChatId,
+		Message:  message,
+		Command:  command,
+		Argument: CommandArgument(text),
+		Args:     CommandArgs(text),
+	})
+
+	return true
+}
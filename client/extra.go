@@ -2,6 +2,7 @@ package client
 
 import (
 	"strings"
+	"unicode"
 
 	"github.com/google/uuid"
 )
@@ -38,14 +39,10 @@ func CheckCommand(text string, entities []*TextEntity) string {
 			}
 		} else {
 			// Since userbot does not have bot command entities in Private Chat, so make userbot happy too!
-			// e.g.: ["/hello@world_bot", "/hello@", "/hello@123"]
+			// Command ends at the first whitespace or "@", whichever comes first, the same boundary as CommandArgument uses.
+			// e.g.: ["/hello@world_bot", "/hello@", "/hello 123", "/hello\n123@world_bot", "/hello"]
 			// Result: "/hello"
-			if i := strings.Index(text, "@"); i != -1 {
-				return text[:i]
-			}
-			// e.g. ["/hello 123", "/hell o 123"]
-			// Result: "/hello", "/hell"
-			if i := strings.Index(text, " "); i != -1 {
+			if i := strings.IndexFunc(text, isCommandEnd); i != -1 {
 				return text[:i]
 			}
 			return text
@@ -54,13 +51,25 @@ func CheckCommand(text string, entities []*TextEntity) string {
 	return ""
 }
 
+func isCommandEnd(r rune) bool {
+	return r == '@' || unicode.IsSpace(r)
+}
+
 func CommandArgument(text string) string {
 	if IsCommand(text) {
-		// e.g. ["/hello 123", "/hell o 123"]
-		// Result: "123", "o 123"
-		if i := strings.Index(text, " "); i != -1 {
-			return text[i+1:]
+		// The "@botname" mention is a part of the command, so argument starts after the first whitespace,
+		// which may be a multibyte rune. The whole whitespace run is skipped.
+		// e.g. ["/hello 123", "/hell o 123", "/hello@world_bot   123", "/hello@world_bot\n123"]
+		// Result: "123", "o 123", "123", "123"
+		if i := strings.IndexFunc(text, unicode.IsSpace); i != -1 {
+			return strings.TrimLeftFunc(text[i:], unicode.IsSpace)
 		}
 	}
 	return ""
 }
+
+// CommandArgs splits command argument by whitespace.
+// e.g. "/hello@world_bot  foo   bar " Result: ["foo", "bar"]
+func CommandArgs(text string) []string {
+	return strings.Fields(CommandArgument(text))
+}
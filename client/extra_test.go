@@ -0,0 +1,83 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"/start", "/start"},
+		{"/start arg", "/start"},
+		{"/start@bot", "/start"},
+		{"/start@bot arg", "/start"},
+		{"/", "/"},
+		{"/start\narg", "/start"},
+		{"/start\u00a0arg", "/start"},
+		{"start", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := CheckCommand(test.text, nil); got != test.want {
+			t.Errorf("CheckCommand(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestCheckCommandEntity(t *testing.T) {
+	entities := []*TextEntity{
+		{
+			Offset: 0,
+			Length: 10,
+			Type:   &TextEntityTypeBotCommand{},
+		},
+	}
+
+	if got := CheckCommand("/start@bot arg", entities); got != "/start" {
+		t.Errorf("CheckCommand with entity = %q, want %q", got, "/start")
+	}
+}
+
+func TestCommandArgument(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"/hello", ""},
+		{"/hello 123", "123"},
+		{"/hello@world_bot 123", "123"},
+		{"/hello   123", "123"},
+		{"/hello \t\n 123 456  ", "123 456  "},
+		{"/hello\u00a0123", "123"},
+		{"/hello\u3000\u3000123", "123"},
+		{"/hello ", ""},
+		{"hello 123", ""},
+	}
+
+	for _, test := range tests {
+		if got := CommandArgument(test.text); got != test.want {
+			t.Errorf("CommandArgument(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"/hello", []string{}},
+		{"/hello@world_bot  foo   bar ", []string{"foo", "bar"}},
+		{"/hello \u3000foo\u00a0bar\n", []string{"foo", "bar"}},
+	}
+
+	for _, test := range tests {
+		if got := CommandArgs(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("CommandArgs(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
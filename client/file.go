@@ -0,0 +1,480 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telegram rejects uploads larger than 2000 MB for regular accounts.
+const maxUploadFileSize = 2000 * 1024 * 1024
+
+// ValidateInputFile checks that a local input file exists, is readable and fits into the upload limit.
+// Other input file types are not checked since only TDLib can resolve them.
+func ValidateInputFile(f InputFile) error {
+	local, ok := f.(*InputFileLocal)
+	if !ok {
+		return nil
+	}
+
+	info, err := os.Stat(local.Path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: is a directory", local.Path)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%s: file is empty", local.Path)
+	}
+	if info.Size() > maxUploadFileSize {
+		return fmt.Errorf("%s: file is too large (%d bytes, max %d)", local.Path, info.Size(), maxUploadFileSize)
+	}
+
+	file, err := os.Open(local.Path)
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// messageFile returns the downloadable file of the message content, the largest size is used for photos.
+func messageFile(content MessageContent) *File {
+	switch content := content.(type) {
+	case *MessagePhoto:
+		if sizes := content.Photo.Sizes; len(sizes) > 0 {
+			return sizes[len(sizes)-1].Photo
+		}
+	case *MessageVideo:
+		return content.Video.Video
+	case *MessageDocument:
+		return content.Document.Document
+	case *MessageAudio:
+		return content.Audio.Audio
+	case *MessageVoiceNote:
+		return content.VoiceNote.Voice
+	case *MessageVideoNote:
+		return content.VideoNote.Video
+	case *MessageAnimation:
+		return content.Animation.Animation
+	case *MessageSticker:
+		return content.Sticker.Sticker
+	}
+
+	return nil
+}
+
+// MessageFileId returns id of the downloadable file of the message, e.g. to be passed to DownloadFileSync.
+// The largest size is used for photos.
+func (client *Client) MessageFileId(msg *Message) (int32, bool) {
+	if msg == nil {
+		return 0, false
+	}
+
+	file := messageFile(msg.Content)
+	if file == nil {
+		return 0, false
+	}
+
+	return file.Id, true
+}
+
+// downloadTo downloads the file and copies it from TDLib files directory into saveDir, keeping files saved earlier.
+func (client *Client) downloadTo(ctx context.Context, fileId int32, saveDir string) (string, error) {
+	file, err := requestAs[*File](ctx, client, "downloadFile", &DownloadFileRequest{
+		FileId:      fileId,
+		Priority:    1,
+		Synchronous: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if !file.Local.IsDownloadingCompleted {
+		return "", fmt.Errorf("file %d: download is not completed", fileId)
+	}
+
+	src, err := os.Open(file.Local.Path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, path, err := createUnique(saveDir, filepath.Base(file.Local.Path))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// createUnique creates a new file named name in dir, adding a suffix like file_0 (1).jpg if the name is taken,
+// since TDLib reuses file names in different directories.
+func createUnique(dir, name string) (*os.File, string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 0; ; i++ {
+		path := filepath.Join(dir, name)
+		if i > 0 {
+			path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+
+		return file, path, err
+	}
+}
+
+// IterateChatMedia searches the chat for messages matching filter, e.g. &SearchMessagesFilterPhoto{},
+// downloads their files into saveDir and streams saved paths.
+// Both channels are closed when the search is done, at most one error is sent.
+func (client *Client) IterateChatMedia(ctx context.Context, chatId int64, filter SearchMessagesFilter, saveDir string) (<-chan string, <-chan error) {
+	paths := make(chan string)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+
+		if err := os.MkdirAll(saveDir, 0755); err != nil {
+			errs <- err
+			return
+		}
+
+		var fromMessageId int64
+		for {
+			found, err := requestAs[*FoundChatMessages](ctx, client, "searchChatMessages", &SearchChatMessagesRequest{
+				ChatId:        chatId,
+				FromMessageId: fromMessageId,
+				Limit:         maxHistoryPageSize,
+				Filter:        filter,
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			for _, message := range found.Messages {
+				file := messageFile(message.Content)
+				if file == nil {
+					continue
+				}
+
+				path, err := client.downloadTo(ctx, file.Id, saveDir)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				select {
+				case paths <- path:
+				case <-client.done:
+					errs <- ErrClientStopped
+					return
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+
+			if found.NextFromMessageId == 0 || len(found.Messages) == 0 {
+				return
+			}
+			fromMessageId = found.NextFromMessageId
+		}
+	}()
+
+	return paths, errs
+}
+
+// WaitUploadComplete blocks until the file, e.g. preliminarily uploaded by UploadFile, is fully uploaded.
+func (client *Client) WaitUploadComplete(ctx context.Context, fileId int32) (*File, error) {
+	waiter := client.waitFor(func(update Type) bool {
+		upd, ok := update.(*UpdateFile)
+		return ok && upd.File.Id == fileId && upd.File.Remote.IsUploadingCompleted
+	})
+
+	file, err := requestAs[*File](ctx, client, "getFile", &GetFileRequest{
+		FileId: fileId,
+	})
+	if err != nil {
+		waiter.stop()
+		return nil, err
+	}
+	if file.Remote.IsUploadingCompleted {
+		waiter.stop()
+		return file, nil
+	}
+
+	update, err := waiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return update.(*UpdateFile).File, nil
+}
+
+// DownloadFileSync starts downloading the file and blocks until it's downloaded. Priority is from 1 to 32,
+// the higher the sooner the file is downloaded.
+func (client *Client) DownloadFileSync(ctx context.Context, fileId int32, priority int32) (*File, error) {
+	download := &downloadState{}
+	waiter := client.waitFor(func(update Type) bool {
+		upd, ok := update.(*UpdateFile)
+		return ok && upd.File.Id == fileId && download.finished(upd.File)
+	})
+
+	file, err := client.startDownload(ctx, fileId, priority)
+	if err != nil {
+		waiter.stop()
+		return nil, err
+	}
+	if file.Local.IsDownloadingCompleted || !file.Local.IsDownloadingActive {
+		waiter.stop()
+		return downloadResult(file)
+	}
+
+	update, err := waiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return downloadResult(update.(*UpdateFile).File)
+}
+
+// DownloadFileWithProgress starts downloading the file and streams downloaded fraction of it from 0 to 1,
+// throttled if WithFileUpdateThrottle is set. The channel is closed once the download is completed or stopped,
+// or ctx is done.
+func (client *Client) DownloadFileWithProgress(ctx context.Context, fileId int32, priority int32) (<-chan float64, error) {
+	progress := make(chan float64, 1)
+	finished := make(chan struct{})
+	var finishOnce sync.Once
+	download := &downloadState{}
+	throttle := newFileThrottle(client.fileUpdateThrottle)
+
+	stop := client.subscribe(10, func(update Type, done <-chan struct{}) {
+		upd, ok := update.(*UpdateFile)
+		if !ok || upd.File.Id != fileId {
+			return
+		}
+
+		if fraction, ok := downloadedFraction(upd.File); ok && throttle.Allow(upd.File) {
+			select {
+			case progress <- fraction:
+			case <-done:
+				return
+			}
+		}
+		if download.finished(upd.File) {
+			finishOnce.Do(func() {
+				close(finished)
+			})
+		}
+	}, func() {
+		close(progress)
+	})
+
+	file, err := client.startDownload(ctx, fileId, priority)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	if file.Local.IsDownloadingCompleted {
+		select {
+		case progress <- 1:
+		default:
+		}
+	}
+	if file.Local.IsDownloadingCompleted || !file.Local.IsDownloadingActive {
+		finishOnce.Do(func() {
+			close(finished)
+		})
+	}
+
+	go func() {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+		case <-client.done:
+		}
+		stop()
+	}()
+
+	return progress, nil
+}
+
+func (client *Client) startDownload(ctx context.Context, fileId int32, priority int32) (*File, error) {
+	file, err := requestAs[*File](ctx, client, "downloadFile", &DownloadFileRequest{
+		FileId:   fileId,
+		Priority: priority,
+	})
+
+	return file, err
+}
+
+// downloadState tells a stopped download from one which isn't started yet by updates of the file, since the file
+// is reported inactive by updates received before the download request is handled too.
+// It's used by the watcher goroutine only, the response to the download request is checked separately.
+type downloadState struct {
+	started bool
+}
+
+// finished reports whether the download is completed, or stopped after an update has reported it active.
+func (download *downloadState) finished(file *File) bool {
+	if file.Local.IsDownloadingCompleted {
+		return true
+	}
+	if file.Local.IsDownloadingActive {
+		download.started = true
+		return false
+	}
+
+	return download.started
+}
+
+func downloadResult(file *File) (*File, error) {
+	if !file.Local.IsDownloadingCompleted {
+		return nil, fmt.Errorf("file %d: download is stopped", file.Id)
+	}
+
+	return file, nil
+}
+
+func downloadedFraction(file *File) (float64, bool) {
+	if file.Local.IsDownloadingCompleted {
+		return 1, true
+	}
+
+	size := file.Size
+	if size == 0 {
+		size = file.ExpectedSize
+	}
+	if size == 0 {
+		return 0, false
+	}
+
+	return float64(file.Local.DownloadedSize) / float64(size), true
+}
+
+// fileThrottle drops updateFile events arriving too often for the same file, see WithFileUpdateThrottle.
+// It isn't safe for concurrent use.
+type fileThrottle struct {
+	interval time.Duration
+	last     map[int32]time.Time
+}
+
+func newFileThrottle(interval time.Duration) *fileThrottle {
+	return &fileThrottle{
+		interval: interval,
+		last:     map[int32]time.Time{},
+	}
+}
+
+func (throttle *fileThrottle) Allow(file *File) bool {
+	if file.Local.IsDownloadingCompleted || file.Remote.IsUploadingCompleted {
+		delete(throttle.last, file.Id)
+		return true
+	}
+	if throttle.interval <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if last, ok := throttle.last[file.Id]; ok && now.Sub(last) < throttle.interval {
+		return false
+	}
+	throttle.last[file.Id] = now
+
+	return true
+}
+
+// OnFileUpdates streams files from updateFile, throttled per file if WithFileUpdateThrottle is set.
+func (client *Client) OnFileUpdates(capacity int) (<-chan *File, func()) {
+	files := make(chan *File, capacity)
+	throttle := newFileThrottle(client.fileUpdateThrottle)
+
+	stop := client.subscribe(capacity, func(update Type, done <-chan struct{}) {
+		upd, ok := update.(*UpdateFile)
+		if !ok || !throttle.Allow(upd.File) {
+			return
+		}
+
+		select {
+		case files <- upd.File:
+		case <-done:
+		}
+	}, func() {
+		close(files)
+	})
+
+	return files, stop
+}
+
+// How many files AutoDownload downloads at once.
+const autoDownloadConcurrency = 4
+
+// AutoDownload downloads files of new messages into saveDir, up to 4 files at once. match picks the file
+// to download, e.g. only photos from a particular chat. Failed downloads are logged. Stop waits for downloads
+// being saved.
+func (client *Client) AutoDownload(match func(message *Message) (fileId int32, ok bool), saveDir string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	slots := make(chan struct{}, autoDownloadConcurrency)
+
+	stopWatching := client.subscribe(100, func(update Type, done <-chan struct{}) {
+		upd, ok := update.(*UpdateNewMessage)
+		if !ok {
+			return
+		}
+
+		fileId, ok := match(upd.Message)
+		if !ok {
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-done:
+			return
+		}
+
+		started := client.goWatched("download", func() {
+			defer func() {
+				<-slots
+			}()
+
+			if err := os.MkdirAll(saveDir, 0755); err != nil {
+				log.Printf("auto download of file %d: %s", fileId, err)
+				return
+			}
+			if _, err := client.downloadTo(ctx, fileId, saveDir); err != nil && ctx.Err() == nil {
+				log.Printf("auto download of file %d: %s", fileId, err)
+			}
+		})
+		if !started {
+			<-slots
+		}
+	}, cancel)
+
+	return func() {
+		stopWatching()
+		cancel()
+	}
+}
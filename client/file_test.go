@@ -0,0 +1,308 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func testFile(id int32, active, completed bool, downloaded int64) string {
+	return fmt.Sprintf(`{"@type":"file","id":%d,"size":100,"expected_size":100,"local":{"@type":"localFile",`+
+		`"path":"/tmp/file","is_downloading_active":%t,"is_downloading_completed":%t,"downloaded_size":%d},`+
+		`"remote":{"@type":"remoteFile"}}`, id, active, completed, downloaded)
+}
+
+func fileUpdate(file string) string {
+	return fmt.Sprintf(`{"@type":"updateFile","file":%s}`, file)
+}
+
+// downloadingClient answers downloadFile with an active download, followed by updates of the file.
+func downloadingClient(updates ...string) *Client {
+	return downloadingClientAfter(nil, updates...)
+}
+
+// downloadingClientAfter works like downloadingClient, but sends early updates before the response.
+func downloadingClientAfter(early []string, updates ...string) *Client {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		if req["@type"] != "downloadFile" {
+			return nil
+		}
+
+		var responses []string
+		for _, update := range early {
+			responses = append(responses, fileUpdate(update))
+		}
+		responses = append(responses, withExtra(testFile(1, true, false, 0), req["@extra"]))
+		for _, update := range updates {
+			responses = append(responses, fileUpdate(update))
+		}
+
+		return responses
+	})
+
+	return client
+}
+
+func TestDownloadFileSyncNoLeak(t *testing.T) {
+	client := downloadingClient(testFile(1, true, false, 50), testFile(1, false, true, 100))
+	defer client.Stop()
+	ignore := goleak.IgnoreCurrent()
+
+	file, err := client.DownloadFileSync(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.Local.IsDownloadingCompleted {
+		t.Fatal("download isn't completed")
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestDownloadFileSyncCancelNoLeak(t *testing.T) {
+	client := downloadingClient()
+	defer client.Stop()
+	ignore := goleak.IgnoreCurrent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.DownloadFileSync(ctx, 1, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestDownloadFileWithProgressNoLeak(t *testing.T) {
+	client := downloadingClient(testFile(1, true, false, 50), testFile(1, false, true, 100))
+	defer client.Stop()
+	ignore := goleak.IgnoreCurrent()
+
+	progress, err := client.DownloadFileWithProgress(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last float64
+	for fraction := range progress {
+		last = fraction
+	}
+	if last != 1 {
+		t.Fatalf("expected final progress 1, got %v", last)
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestDownloadFileWithProgressCancelNoLeak(t *testing.T) {
+	client := downloadingClient()
+	defer client.Stop()
+	ignore := goleak.IgnoreCurrent()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress, err := client.DownloadFileWithProgress(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	for range progress {
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestWaitUploadCompleteCancelNoLeak(t *testing.T) {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"file","id":1,"local":{"@type":"localFile"},"remote":{"@type":"remoteFile"}}`, req["@extra"])}
+	})
+	defer client.Stop()
+	ignore := goleak.IgnoreCurrent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.WaitUploadComplete(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestStopNoLeak(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+
+	client := downloadingClient()
+	progress, err := client.DownloadFileWithProgress(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := client.OnFileUpdates(10)
+
+	client.Stop()
+
+	for range progress {
+	}
+	for range files {
+	}
+
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestSubscribeAfterStop(t *testing.T) {
+	client := NewTestClient()
+	client.Stop()
+
+	files, _ := client.OnFileUpdates(10)
+	if _, ok := <-files; ok {
+		t.Fatal("stream of a stopped client isn't closed")
+	}
+}
+
+func TestDownloadFileSyncInactiveBeforeStart(t *testing.T) {
+	client := downloadingClientAfter([]string{testFile(1, false, false, 0)}, testFile(1, false, true, 100))
+	defer client.Stop()
+
+	file, err := client.DownloadFileSync(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.Local.IsDownloadingCompleted {
+		t.Fatal("download isn't completed")
+	}
+}
+
+func TestDownloadFileSyncStopped(t *testing.T) {
+	client := downloadingClient(testFile(1, true, false, 50), testFile(1, false, false, 50))
+	defer client.Stop()
+
+	_, err := client.DownloadFileSync(context.Background(), 1, 1)
+	if err == nil {
+		t.Fatal("stopped download isn't reported")
+	}
+}
+
+func TestDownloadFileWithProgressThrottled(t *testing.T) {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		if req["@type"] != "downloadFile" {
+			return nil
+		}
+
+		return []string{
+			withExtra(testFile(1, true, false, 0), req["@extra"]),
+			fileUpdate(testFile(1, true, false, 10)),
+			fileUpdate(testFile(1, true, false, 20)),
+			fileUpdate(testFile(1, true, false, 30)),
+			fileUpdate(testFile(1, false, true, 100)),
+		}
+	}, WithFileUpdateThrottle(time.Hour))
+	defer client.Stop()
+
+	progress, err := client.DownloadFileWithProgress(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fractions []float64
+	for fraction := range progress {
+		fractions = append(fractions, fraction)
+	}
+	if len(fractions) != 2 || fractions[0] != 0.1 || fractions[1] != 1 {
+		t.Fatalf("unexpected progress %v", fractions)
+	}
+}
+
+func TestAutoDownloadBoundedAndStopped(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "file_0.jpg")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var active, maxActive int32
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		if req["@type"] != "downloadFile" {
+			return nil
+		}
+
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			current := atomic.LoadInt32(&maxActive)
+			if n <= current || atomic.CompareAndSwapInt32(&maxActive, current, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		return []string{withExtra(fmt.Sprintf(`{"@type":"file","id":%s,"size":4,"local":{"@type":"localFile",`+
+			`"path":%q,"is_downloading_completed":true,"downloaded_size":4},"remote":{"@type":"remoteFile"}}`,
+			req["file_id"], src), req["@extra"])}
+	})
+
+	client.AutoDownload(func(message *Message) (int32, bool) {
+		return int32(message.Id), true
+	}, filepath.Join(dir, "saved"))
+
+	for id := 1; id <= 10; id++ {
+		feed(t, client, fmt.Sprintf(`{"@type":"updateNewMessage","message":%s}`, testMessage(int64(id), "")))
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	client.Stop()
+	if n := atomic.LoadInt32(&active); n != 0 {
+		t.Fatalf("%d downloads are running after Stop", n)
+	}
+	if n := atomic.LoadInt32(&maxActive); n > autoDownloadConcurrency {
+		t.Fatalf("%d downloads were running at once", n)
+	}
+}
+
+func TestDownloadToKeepsFilesWithSameName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"photos", "thumbnails"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "file_0.jpg"), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		path := filepath.Join(dir, "photos", "file_0.jpg")
+		if fmt.Sprint(req["file_id"]) == "2" {
+			path = filepath.Join(dir, "thumbnails", "file_0.jpg")
+		}
+
+		return []string{withExtra(fmt.Sprintf(`{"@type":"file","id":%s,"local":{"@type":"localFile",`+
+			`"path":%q,"is_downloading_completed":true},"remote":{"@type":"remoteFile"}}`, req["file_id"], path), req["@extra"])}
+	})
+	defer client.Stop()
+
+	saveDir := t.TempDir()
+	for fileId, content := range map[int32]string{1: "photos", 2: "thumbnails"} {
+		path, err := client.downloadTo(context.Background(), fileId, saveDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("file %d is saved as %s with %q", fileId, path, data)
+		}
+	}
+
+	if saved, _ := os.ReadDir(saveDir); len(saved) != 2 {
+		t.Fatalf("expected 2 saved files, got %d", len(saved))
+	}
+}
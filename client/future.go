@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+)
+
+// Future is a response of the request sent by SendAsync.
+type Future struct {
+	done     chan struct{}
+	response *Response
+	err      error
+	cancel   context.CancelFunc
+}
+
+// SendAsync sends the request without waiting for its response, e.g. to send many requests at once
+// and collect responses later. The request is sent before SendAsync returns, so requests are sent in order
+// of SendAsync calls; it blocks meanwhile if WithRateLimit or WithMaxConcurrentRequests makes the request wait.
+// Like Send, the response is waited for up to the catch timeout, see WithCatchTimeout and WithMethodTimeouts,
+// so an abandoned Future is released afterwards.
+func (client *Client) SendAsync(req Request) *Future {
+	ctx, cancel := context.WithCancel(context.Background())
+	future := &Future{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+
+	req.Extra = client.extraGenerator()
+	req = client.applyRequestMiddlewares(req)
+
+	var started *startedRequest
+	err := req.blocked
+	if err == nil {
+		started, err = client.startRequest(ctx, req)
+	}
+	if err != nil {
+		cancel()
+		future.err = err
+		close(future.done)
+		return future
+	}
+
+	go func() {
+		defer cancel()
+
+		future.response, future.err = client.finishSend(ctx, req, started)
+		close(future.done)
+	}()
+
+	return future
+}
+
+// Done is closed once the response is received or the request failed.
+func (future *Future) Done() <-chan struct{} {
+	return future.done
+}
+
+// Wait returns the response like Send does. It gives up with ctx.Err() once ctx is done,
+// leaving the request running, use Cancel to stop waiting for its response.
+func (future *Future) Wait(ctx context.Context) (*Response, error) {
+	select {
+	case <-future.done:
+		return future.response, future.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel stops waiting for the response and releases the request, so Wait returns context.Canceled
+// unless the response has been received already. The request itself may still be executed by TDLib.
+func (future *Future) Cancel() {
+	future.cancel()
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSendAsyncSendsBeforeReturning(t *testing.T) {
+	client, transport := newMockClient(nil)
+	defer client.Stop()
+
+	future := client.SendAsync(Request{
+		meta: meta{
+			Type: "getMe",
+		},
+		Data: map[string]interface{}{},
+	})
+
+	sent := transport.requests()
+	if len(sent) != 1 || sent[0]["@type"] != "getMe" {
+		t.Fatalf("request isn't sent when SendAsync returns: %v", sent)
+	}
+	if _, ok := client.catchersStore.Load(sent[0]["@extra"]); !ok {
+		t.Fatal("catcher isn't registered when SendAsync returns")
+	}
+
+	if err := client.FeedUpdate([]byte(withExtra(`{"@type":"user","id":42}`, sent[0]["@extra"]))); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response, err := future.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != "user" {
+		t.Fatalf("unexpected response %s", response.Type)
+	}
+}
+
+func TestSendAsyncKeepsOrder(t *testing.T) {
+	client, transport := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"ok"}`, req["@extra"])}
+	})
+	defer client.Stop()
+
+	futures := make([]*Future, 10)
+	for i := range futures {
+		futures[i] = client.SendAsync(Request{
+			meta: meta{
+				Type: "setOption",
+			},
+			Data: map[string]interface{}{
+				"name": "test",
+				"value": map[string]interface{}{
+					"@type": "optionValueInteger",
+					"value": i,
+				},
+			},
+		})
+	}
+
+	for _, future := range futures {
+		if _, err := future.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, req := range transport.requests() {
+		value := req["value"].(map[string]interface{})["value"]
+		if fmt.Sprint(value) != strconv.Itoa(i) {
+			t.Fatalf("request %d is sent out of order: %v", i, value)
+		}
+	}
+}
+
+func TestSendAsyncStopped(t *testing.T) {
+	client, transport := newMockClient(nil)
+	client.Stop()
+	stopped := len(transport.requests())
+
+	future := client.SendAsync(Request{
+		meta: meta{
+			Type: "getMe",
+		},
+		Data: map[string]interface{}{},
+	})
+
+	select {
+	case <-future.Done():
+	default:
+		t.Fatal("future of the stopped client isn't done")
+	}
+	if _, err := future.Wait(context.Background()); err != ErrClientStopped {
+		t.Fatalf("expected ErrClientStopped, got %v", err)
+	}
+	if sent := transport.requests(); len(sent) != stopped {
+		t.Fatalf("unexpected requests %v", sent)
+	}
+}
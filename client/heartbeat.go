@@ -0,0 +1,79 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Synthetic update type, never sent by TDLib.
+const TypeUpdateHeartbeat = "gotdlibUpdateHeartbeat"
+
+// Sent periodically by the client itself if WithHeartbeat is set, after passing through the same queue as TDLib updates.
+type UpdateHeartbeat struct {
+	meta
+	// Unix time in nanoseconds when the heartbeat was queued
+	Time int64 `json:"time"`
+}
+
+func (entity *UpdateHeartbeat) MarshalJSON() ([]byte, error) {
+	entity.meta.Type = entity.GetType()
+
+	type stub UpdateHeartbeat
+
+	return json.Marshal((*stub)(entity))
+}
+
+func (*UpdateHeartbeat) GetClass() string {
+	return ClassUpdate
+}
+
+func (*UpdateHeartbeat) GetType() string {
+	return TypeUpdateHeartbeat
+}
+
+func (*UpdateHeartbeat) UpdateType() string {
+	return TypeUpdateHeartbeat
+}
+
+// Emit UpdateHeartbeat every interval, so listeners can detect a stuck update queue.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(client *Client) {
+		client.heartbeatInterval = interval
+	}
+}
+
+func (client *Client) heartbeat() {
+	ticker := time.NewTicker(client.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			data, _ := json.Marshal(&UpdateHeartbeat{
+				Time: now.UnixNano(),
+			})
+			response := &Response{
+				meta: meta{
+					Type: TypeUpdateHeartbeat,
+				},
+				Data: data,
+			}
+
+			select {
+			case client.responses <- response:
+			case <-client.done:
+				return
+			}
+		case <-client.done:
+			return
+		}
+	}
+}
+
+func unmarshalHeartbeat(data json.RawMessage) (*UpdateHeartbeat, error) {
+	var heartbeat UpdateHeartbeat
+
+	err := json.Unmarshal(data, &heartbeat)
+
+	return &heartbeat, err
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// updateHistory keeps the last dispatched updates, see WithUpdateHistory.
+type updateHistory struct {
+	mu      sync.Mutex
+	updates []Type
+	next    int
+	full    bool
+}
+
+func newUpdateHistory(size int) *updateHistory {
+	return &updateHistory{
+		updates: make([]Type, size),
+	}
+}
+
+func (history *updateHistory) Add(update Type) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.updates[history.next] = update
+	history.next = (history.next + 1) % len(history.updates)
+	if history.next == 0 {
+		history.full = true
+	}
+}
+
+// Updates returns kept updates from the oldest to the newest.
+func (history *updateHistory) Updates() []Type {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if !history.full {
+		return append([]Type{}, history.updates[:history.next]...)
+	}
+
+	return append(append([]Type{}, history.updates[history.next:]...), history.updates[:history.next]...)
+}
+
+// rawUpdateHistory keeps undecoded JSON of the last dispatched updates up to maxBytes in total,
+// see WithRawUpdateHistory.
+type rawUpdateHistory struct {
+	mu       sync.Mutex
+	updates  []json.RawMessage
+	size     int
+	maxBytes int
+}
+
+func newRawUpdateHistory(maxBytes int) *rawUpdateHistory {
+	return &rawUpdateHistory{
+		maxBytes: maxBytes,
+	}
+}
+
+func (history *rawUpdateHistory) Add(data json.RawMessage) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if len(data) > history.maxBytes {
+		return
+	}
+
+	history.updates = append(history.updates, data)
+	history.size += len(data)
+	for history.size > history.maxBytes {
+		history.size -= len(history.updates[0])
+		history.updates = history.updates[1:]
+	}
+}
+
+// Updates decodes kept updates from the oldest to the newest, skipping the ones that can't be decoded.
+func (history *rawUpdateHistory) Updates(decode func(data json.RawMessage) (Type, error)) []Type {
+	history.mu.Lock()
+	updates := append([]json.RawMessage{}, history.updates...)
+	history.mu.Unlock()
+
+	types := make([]Type, 0, len(updates))
+	for _, data := range updates {
+		typ, err := decode(data)
+		if err != nil {
+			continue
+		}
+		types = append(types, typ)
+	}
+
+	return types
+}
+
+// Keep the last n updates received by the client for debugging, see RecentUpdates.
+func WithUpdateHistory(n int) Option {
+	return func(client *Client) {
+		if n > 0 {
+			client.history = newUpdateHistory(n)
+		}
+	}
+}
+
+// Same as WithUpdateHistory, but keeps raw JSON of updates up to maxBytes in total instead of a number of
+// decoded updates. Updates are decoded by each RecentUpdates call, which bounds memory used on busy clients.
+func WithRawUpdateHistory(maxBytes int) Option {
+	return func(client *Client) {
+		if maxBytes > 0 {
+			client.rawHistory = newRawUpdateHistory(maxBytes)
+		}
+	}
+}
+
+// RecentUpdates returns the last updates received by the client from the oldest to the newest.
+// It is empty unless WithUpdateHistory or WithRawUpdateHistory is used.
+func (client *Client) RecentUpdates() []Type {
+	if client.rawHistory != nil {
+		return client.rawHistory.Updates(client.unmarshalType)
+	}
+	if client.history == nil {
+		return nil
+	}
+
+	return client.history.Updates()
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 func newListenerStore() *listenerStore {
@@ -19,9 +20,31 @@ func (store *listenerStore) Add(listener *Listener) {
 	store.Lock()
 	defer store.Unlock()
 
+	if listener.done == nil {
+		listener.done = make(chan struct{})
+	}
+	listener.store = store
 	store.listeners = append(store.listeners, listener)
 }
 
+func (store *listenerStore) remove(listener *Listener) {
+	store.Lock()
+	defer store.Unlock()
+
+	store.listeners = removeListener(store.listeners, listener)
+}
+
+func removeListener(listeners []*Listener, listener *Listener) []*Listener {
+	kept := []*Listener{}
+	for _, l := range listeners {
+		if l != listener {
+			kept = append(kept, l)
+		}
+	}
+
+	return kept
+}
+
 func (store *listenerStore) Listeners() []*Listener {
 	store.Lock()
 	defer store.Unlock()
@@ -45,29 +68,193 @@ func (store *listenerStore) gc() {
 }
 
 type Listener struct {
+	// Accessed atomically, keep it first for 64-bit alignment.
+	dropped uint64
+
 	mu         sync.Mutex
 	isActive   bool
 	Updates    chan Type
 	RawUpdates chan Type
+	SeqUpdates chan SeqUpdate
 	Filter     Type
+	// Set of accepted update types, replaces Filter if not nil.
+	types map[string]struct{}
+
+	store     *listenerStore
+	done      chan struct{}
+	closeOnce sync.Once
+	// Held for reading while delivering, for writing while closing channels.
+	sendMu sync.RWMutex
+	closed bool
+	// Drop updates instead of waiting while the channel is full, see WithDropOnFull.
+	dropOnFull bool
+	// Listeners of helpers don't take the startup buffer, see WithStartupBuffer.
+	internal bool
 }
 
+// SeqUpdate is an update numbered in the order updates are received by the client.
+type SeqUpdate struct {
+	Seq    uint64
+	Update Type
+}
+
+// Close unsubscribes the listener and closes its channels. It is safe to call more than once
+// and while updates are being delivered.
 func (listener *Listener) Close() {
-	listener.mu.Lock()
-	defer listener.mu.Unlock()
+	listener.closeOnce.Do(func() {
+		listener.mu.Lock()
+		listener.isActive = false
+		listener.mu.Unlock()
+
+		// Unblock pending deliveries before waiting for them.
+		if listener.done != nil {
+			close(listener.done)
+		}
+
+		listener.sendMu.Lock()
+		listener.closed = true
+		if listener.Updates != nil {
+			close(listener.Updates)
+		}
+		if listener.RawUpdates != nil {
+			close(listener.RawUpdates)
+		}
+		if listener.SeqUpdates != nil {
+			close(listener.SeqUpdates)
+		}
+		listener.sendMu.Unlock()
+
+		if listener.store != nil {
+			listener.store.remove(listener)
+		}
+	})
+}
+
+// deliver sends the update to the channel unless the listener is closed meanwhile.
+func (listener *Listener) deliver(updates chan Type, typ Type) {
+	listener.sendMu.RLock()
+	defer listener.sendMu.RUnlock()
 
-	listener.isActive = false
-	if listener.Updates != nil {
-		close(listener.Updates)
+	if listener.closed {
+		return
 	}
-	if listener.RawUpdates != nil {
-		close(listener.RawUpdates)
+
+	if listener.dropOnFull {
+		select {
+		case updates <- typ:
+		default:
+			atomic.AddUint64(&listener.dropped, 1)
+		}
+		return
+	}
+
+	select {
+	case updates <- typ:
+	case <-listener.done:
 	}
 }
 
+// deliverSeq is the same as deliver for SeqUpdates channel.
+func (listener *Listener) deliverSeq(update SeqUpdate) {
+	listener.sendMu.RLock()
+	defer listener.sendMu.RUnlock()
+
+	if listener.closed {
+		return
+	}
+
+	if listener.dropOnFull {
+		select {
+		case listener.SeqUpdates <- update:
+		default:
+			atomic.AddUint64(&listener.dropped, 1)
+		}
+		return
+	}
+
+	select {
+	case listener.SeqUpdates <- update:
+	case <-listener.done:
+	}
+}
+
+// accepts reports whether dispatch would deliver the update to the listener.
+func (listener *Listener) accepts(typ Type) bool {
+	switch {
+	case !listener.IsActive():
+		return false
+	case listener.Updates != nil:
+		return listener.matches(typ)
+	case listener.RawUpdates != nil:
+		return true
+	case listener.SeqUpdates != nil:
+		return listener.unfiltered() || listener.matches(typ)
+	}
+
+	return false
+}
+
+// deliverUpdate sends the update to the channel of the listener, numbered by seq for SeqUpdates.
+func (listener *Listener) deliverUpdate(typ Type, seq uint64) {
+	switch {
+	case listener.Updates != nil:
+		listener.deliver(listener.Updates, typ)
+	case listener.RawUpdates != nil:
+		listener.deliver(listener.RawUpdates, typ)
+	case listener.SeqUpdates != nil:
+		listener.deliverSeq(SeqUpdate{
+			Seq:    seq,
+			Update: typ,
+		})
+	}
+}
+
+// Dropped returns the number of updates dropped because the listener channel was full, see WithDropOnFull.
+func (listener *Listener) Dropped() uint64 {
+	return atomic.LoadUint64(&listener.dropped)
+}
+
 func (listener *Listener) IsActive() bool {
 	listener.mu.Lock()
 	defer listener.mu.Unlock()
 
 	return listener.isActive
 }
+
+// GetFilter returns the update type the listener is subscribed to.
+func (listener *Listener) GetFilter() Type {
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+
+	return listener.Filter
+}
+
+// SetFilter changes the update type the listener is subscribed to, without recreating its channel.
+func (listener *Listener) SetFilter(filter Type) {
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+
+	listener.Filter = filter
+	listener.types = nil
+}
+
+// matches reports whether the update is accepted by the listener filter or its set of types.
+func (listener *Listener) matches(typ Type) bool {
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+
+	if listener.types != nil {
+		_, ok := listener.types[typ.GetType()]
+		return ok
+	}
+
+	return listener.Filter != nil && typ.GetType() == listener.Filter.GetType()
+}
+
+// unfiltered reports whether the listener has neither a filter nor a set of types.
+func (listener *Listener) unfiltered() bool {
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+
+	return listener.Filter == nil && listener.types == nil
+}
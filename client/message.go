@@ -0,0 +1,659 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Telegram accepts 2-10 messages in one album.
+const (
+	minAlbumSize = 2
+	maxAlbumSize = 10
+)
+
+var ErrAlbumTooLarge = fmt.Errorf("album can contain at most %d items", maxAlbumSize)
+
+// SendAlbum sends 2-10 local photos and videos as one album.
+// Media type is detected by file extension, caption is attached to the first item.
+func (client *Client) SendAlbum(ctx context.Context, chatId int64, paths []string, caption *FormattedText) ([]*Message, error) {
+	if len(paths) > maxAlbumSize {
+		return nil, ErrAlbumTooLarge
+	}
+	if len(paths) < minAlbumSize {
+		return nil, fmt.Errorf("album must have %d-%d items, got %d", minAlbumSize, maxAlbumSize, len(paths))
+	}
+
+	contents := make([]InputMessageContent, 0, len(paths))
+	for i, path := range paths {
+		var itemCaption *FormattedText
+		if i == 0 {
+			itemCaption = caption
+		}
+
+		content, err := albumContent(path, itemCaption)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+
+	messages, err := requestAs[*Messages](ctx, client, "sendMessageAlbum", &SendMessageAlbumRequest{
+		ChatId:               chatId,
+		InputMessageContents: contents,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages.Messages, nil
+}
+
+func albumContent(path string, caption *FormattedText) (InputMessageContent, error) {
+	content, err := mediaContent(path, caption)
+	if err != nil {
+		return nil, err
+	}
+
+	switch content.(type) {
+	case *InputMessagePhoto, *InputMessageVideo:
+		return content, nil
+	}
+
+	return nil, fmt.Errorf("%s: unsupported album media type", path)
+}
+
+// mediaContent builds photo, video, audio or document content of a local file detected by its extension.
+func mediaContent(path string, caption *FormattedText) (InputMessageContent, error) {
+	file := &InputFileLocal{
+		Path: path,
+	}
+	if err := ValidateInputFile(file); err != nil {
+		return nil, err
+	}
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return &InputMessagePhoto{
+			Photo:   file,
+			Caption: caption,
+		}, nil
+
+	case strings.HasPrefix(mimeType, "video/"):
+		return &InputMessageVideo{
+			Video:             file,
+			Caption:           caption,
+			SupportsStreaming: true,
+		}, nil
+
+	case strings.HasPrefix(mimeType, "audio/"):
+		return &InputMessageAudio{
+			Audio:   file,
+			Caption: caption,
+		}, nil
+	}
+
+	return &InputMessageDocument{
+		Document: file,
+		Caption:  caption,
+	}, nil
+}
+
+// React adds an emoji reaction to the message and puts it into recent reactions.
+func (client *Client) React(ctx context.Context, chatId, messageId int64, emoji string) error {
+	return client.addReaction(ctx, chatId, messageId, &ReactionTypeEmoji{
+		Emoji: emoji,
+	})
+}
+
+// ReactCustom adds a custom emoji reaction to the message.
+func (client *Client) ReactCustom(ctx context.Context, chatId, messageId int64, customEmojiId int64) error {
+	return client.addReaction(ctx, chatId, messageId, &ReactionTypeCustomEmoji{
+		CustomEmojiId: JsonInt64(customEmojiId),
+	})
+}
+
+func (client *Client) addReaction(ctx context.Context, chatId, messageId int64, reaction ReactionType) error {
+	_, err := client.request(ctx, "addMessageReaction", &AddMessageReactionRequest{
+		ChatId:                chatId,
+		MessageId:             messageId,
+		ReactionType:          reaction,
+		UpdateRecentReactions: true,
+	})
+	return err
+}
+
+// RemoveReaction removes a chosen reaction from the message, e.g. &ReactionTypeEmoji{Emoji: "👍"}.
+func (client *Client) RemoveReaction(ctx context.Context, chatId, messageId int64, reaction ReactionType) error {
+	_, err := client.request(ctx, "removeMessageReaction", &RemoveMessageReactionRequest{
+		ChatId:       chatId,
+		MessageId:    messageId,
+		ReactionType: reaction,
+	})
+	return err
+}
+
+// ScheduledMessages returns all scheduled messages of the chat, ordered by scheduled date.
+func (client *Client) ScheduledMessages(ctx context.Context, chatId int64) ([]*Message, error) {
+	messages, err := requestAs[*Messages](ctx, client, "getChatScheduledMessages", &GetChatScheduledMessagesRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages.Messages, nil
+}
+
+// SendAndSettle sends a message request, e.g. sendMessage, and waits until the sent message
+// becomes the last message of the chat, so the following getChatHistory returns it.
+func (client *Client) SendAndSettle(ctx context.Context, req Request) (*Response, error) {
+	chatId, ok := requestChatId(req)
+	if !ok {
+		return nil, fmt.Errorf("%s: request has no chat_id", req.Type)
+	}
+
+	// Temporary message id is replaced by the real one when the message is sent,
+	// so keep track of both kinds of updates.
+	type settled struct {
+		oldId int64
+		id    int64
+	}
+	events := make(chan settled, 100)
+	stop := client.subscribe(100, func(update Type, done <-chan struct{}) {
+		var event settled
+
+		switch upd := update.(type) {
+		case *UpdateChatLastMessage:
+			if upd.ChatId != chatId || upd.LastMessage == nil {
+				return
+			}
+			event.id = upd.LastMessage.Id
+		case *UpdateMessageSendSucceeded:
+			if upd.Message.ChatId != chatId {
+				return
+			}
+			event.oldId = upd.OldMessageId
+			event.id = upd.Message.Id
+		default:
+			return
+		}
+
+		select {
+		case events <- event:
+		case <-done:
+		}
+	}, func() {})
+	defer stop()
+
+	response, err := client.SendContext(ctx, req)
+	if err != nil {
+		// Error response is returned along with the error, as Send does.
+		return response, err
+	}
+
+	message := &Message{}
+	err = client.unmarshalJSON(response.Data, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, failed := message.SendingState.(*MessageSendingStateFailed); failed {
+		return response, nil
+	}
+
+	// Patched or already sent message has no sending state and carries the real id.
+	messageId := message.Id
+	pending := message.SendingState != nil
+	for {
+		select {
+		case event := <-events:
+			if pending {
+				if event.oldId == messageId {
+					messageId = event.id
+					pending = false
+				}
+				continue
+			}
+			if event.oldId == 0 && event.id == messageId {
+				return response, nil
+			}
+		case <-client.done:
+			return nil, ErrClientStopped
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrMessageUnsupported = errors.New("message is not supported by the current TDLib version")
+)
+
+// Message returns the message, ErrMessageNotFound if it doesn't exist or isn't accessible
+// and ErrMessageUnsupported along with the message if its content is unknown to TDLib.
+func (client *Client) Message(ctx context.Context, chatId, messageId int64) (*Message, error) {
+	message, err := requestAs[*Message](ctx, client, "getMessage", &GetMessageRequest{
+		ChatId:    chatId,
+		MessageId: messageId,
+	})
+	if err != nil {
+		var respErr ResponseError
+		if errors.As(err, &respErr) && respErr.Err.Code == 404 {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	if message.Content != nil && message.Content.MessageContentType() == TypeMessageUnsupported {
+		return message, ErrMessageUnsupported
+	}
+
+	return message, nil
+}
+
+// EditMedia replaces the media of the message with a local file and returns the message
+// once updateMessageContent with the new content arrives.
+func (client *Client) EditMedia(ctx context.Context, chatId, messageId int64, path string, caption *FormattedText) (*Message, error) {
+	content, err := mediaContent(path, caption)
+	if err != nil {
+		return nil, err
+	}
+
+	waiter := client.waitFor(func(update Type) bool {
+		upd, ok := update.(*UpdateMessageContent)
+		return ok && upd.ChatId == chatId && upd.MessageId == messageId
+	})
+
+	message, err := requestAs[*Message](ctx, client, "editMessageMedia", &EditMessageMediaRequest{
+		ChatId:              chatId,
+		MessageId:           messageId,
+		InputMessageContent: content,
+	})
+	if err != nil {
+		waiter.stop()
+		return nil, err
+	}
+
+	update, err := waiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	message.Content = update.(*UpdateMessageContent).NewContent
+
+	return message, nil
+}
+
+// SenderName returns full name of the user or title of the chat which sent a message.
+func (client *Client) SenderName(ctx context.Context, sender MessageSender) (string, error) {
+	switch sender := sender.(type) {
+	case *MessageSenderUser:
+		user, err := requestAs[*User](ctx, client, "getUser", &GetUserRequest{
+			UserId: sender.UserId,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(user.FirstName + " " + user.LastName), nil
+
+	case *MessageSenderChat:
+		chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+			ChatId: sender.ChatId,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return chat.Title, nil
+	}
+
+	return "", fmt.Errorf("unsupported message sender %T", sender)
+}
+
+// messageText returns the text of text messages or the caption of media messages.
+func messageText(content MessageContent) *FormattedText {
+	switch content := content.(type) {
+	case *MessageText:
+		return content.Text
+	case *MessagePhoto:
+		return content.Caption
+	case *MessageVideo:
+		return content.Caption
+	case *MessageDocument:
+		return content.Caption
+	case *MessageAudio:
+		return content.Caption
+	case *MessageVoiceNote:
+		return content.Caption
+	case *MessageAnimation:
+		return content.Caption
+	}
+
+	return nil
+}
+
+// entityText returns the part of the text covered by the entity, whose offset and length are in UTF-16 code units.
+func entityText(text string, entity *TextEntity) string {
+	units := utf16.Encode([]rune(text))
+	if entity.Offset < 0 || int(entity.Offset+entity.Length) > len(units) {
+		return ""
+	}
+
+	return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+}
+
+// MentionsMe reports whether the message mentions the current user by username or by name,
+// has an unread mention or is a reply to a message of the current user.
+func (client *Client) MentionsMe(ctx context.Context, msg *Message) (bool, error) {
+	if msg.ContainsUnreadMention {
+		return true, nil
+	}
+
+	me, err := requestAs[*User](ctx, client, "getMe", nil)
+	if err != nil {
+		return false, err
+	}
+
+	if text := messageText(msg.Content); text != nil {
+		for _, entity := range text.Entities {
+			switch entityType := entity.Type.(type) {
+			case *TextEntityTypeMentionName:
+				if entityType.UserId == me.Id {
+					return true, nil
+				}
+
+			case *TextEntityTypeMention:
+				// Mention text includes leading "@".
+				username := strings.TrimPrefix(entityText(text.Text, entity), "@")
+				if me.Usernames != nil {
+					for _, active := range me.Usernames.ActiveUsernames {
+						if strings.EqualFold(username, active) {
+							return true, nil
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if _, ok := msg.ReplyTo.(*MessageReplyToMessage); !ok {
+		return false, nil
+	}
+
+	replied, err := requestAs[*Message](ctx, client, "getRepliedMessage", &GetRepliedMessageRequest{
+		ChatId:    msg.ChatId,
+		MessageId: msg.Id,
+	})
+	if err != nil {
+		// The replied message may be deleted or inaccessible.
+		var respErr ResponseError
+		if errors.As(err, &respErr) && respErr.Err.Code == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	sender, ok := replied.SenderId.(*MessageSenderUser)
+
+	return ok && sender.UserId == me.Id, nil
+}
+
+// IterateThreadHistory streams all messages of the message thread, e.g. comments of a channel post,
+// from the newest to the oldest. Both channels are closed at the end, at most one error is sent.
+func (client *Client) IterateThreadHistory(ctx context.Context, chatId, messageId int64, pageSize int32) (<-chan *Message, <-chan error) {
+	messages := make(chan *Message)
+	errs := make(chan error, 1)
+
+	if pageSize <= 0 || pageSize > maxHistoryPageSize {
+		pageSize = maxHistoryPageSize
+	}
+
+	go func() {
+		defer close(messages)
+		defer close(errs)
+
+		var fromMessageId int64
+		for {
+			page, err := requestAs[*Messages](ctx, client, "getMessageThreadHistory", &GetMessageThreadHistoryRequest{
+				ChatId:        chatId,
+				MessageId:     messageId,
+				FromMessageId: fromMessageId,
+				Limit:         pageSize,
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			sent := 0
+			for _, message := range page.Messages {
+				// The page starts from exactly from_message_id, which has been sent already.
+				if fromMessageId != 0 && message.Id >= fromMessageId {
+					continue
+				}
+
+				select {
+				case messages <- message:
+					sent++
+				case <-client.done:
+					errs <- ErrClientStopped
+					return
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+			if sent == 0 {
+				return
+			}
+
+			fromMessageId = page.Messages[len(page.Messages)-1].Id
+		}
+	}()
+
+	return messages, errs
+}
+
+// SendContact sends a contact card. Pass 0 as userId if the contact isn't a known Telegram user.
+func (client *Client) SendContact(ctx context.Context, chatId int64, phone, first, last string, userId int64) (*Message, error) {
+	if strings.TrimSpace(phone) == "" {
+		return nil, errors.New("contact phone number is required")
+	}
+
+	message, err := requestAs[*Message](ctx, client, "sendMessage", &SendMessageRequest{
+		ChatId: chatId,
+		InputMessageContent: &InputMessageContact{
+			Contact: &Contact{
+				PhoneNumber: phone,
+				FirstName:   first,
+				LastName:    last,
+				UserId:      userId,
+			},
+		},
+	})
+
+	return message, err
+}
+
+// Telegram polls have 2-10 answer options.
+const (
+	minPollOptions = 2
+	maxPollOptions = 10
+)
+
+// SendPoll sends a regular poll, multiple allows choosing several options at once.
+func (client *Client) SendPoll(ctx context.Context, chatId int64, question string, options []string, anonymous bool, multiple bool) (*Message, error) {
+	if strings.TrimSpace(question) == "" {
+		return nil, errors.New("poll question is required")
+	}
+	if len(options) < minPollOptions || len(options) > maxPollOptions {
+		return nil, fmt.Errorf("poll must have %d-%d options, got %d", minPollOptions, maxPollOptions, len(options))
+	}
+
+	pollOptions := make([]*FormattedText, 0, len(options))
+	for _, option := range options {
+		pollOptions = append(pollOptions, &FormattedText{
+			Text: option,
+		})
+	}
+
+	message, err := requestAs[*Message](ctx, client, "sendMessage", &SendMessageRequest{
+		ChatId: chatId,
+		InputMessageContent: &InputMessagePoll{
+			Question: &FormattedText{
+				Text: question,
+			},
+			Options:     pollOptions,
+			IsAnonymous: anonymous,
+			Type: &PollTypeRegular{
+				AllowMultipleAnswers: multiple,
+			},
+		},
+	})
+
+	return message, err
+}
+
+// How long a message sent with an idempotency key is remembered, counting from the first attempt.
+const idempotencyWindow = 10 * time.Minute
+
+// ErrSendPending is returned when the message sent with an idempotency key isn't confirmed by TDLib in time.
+// The attempt is kept, so sending again with the same key returns the message once it's sent instead of a duplicate.
+var ErrSendPending = errors.New("message sending isn't confirmed yet")
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey returns ctx making the message helpers, e.g. SendText, SendContact and SendPoll,
+// send the message only once per key during 10 minutes; sending it again returns the earlier message.
+// It applies to sendMessage requests sent by SendContext too. Only attempts rejected by TDLib are forgotten,
+// so an attempt which timed out is waited for until the message is sent.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+type idempotentSend struct {
+	done     chan struct{}
+	response *Response
+	err      error
+	expires  time.Time
+}
+
+// sendIdempotent sends the request once per key, see WithIdempotencyKey.
+func (client *Client) sendIdempotent(ctx context.Context, key string, req Request) (*Response, error) {
+	client.idempotentMu.Lock()
+	if client.idempotentSends == nil {
+		client.idempotentSends = map[string]*idempotentSend{}
+	}
+	now := time.Now()
+	for k, send := range client.idempotentSends {
+		if now.After(send.expires) {
+			delete(client.idempotentSends, k)
+		}
+	}
+
+	send, ok := client.idempotentSends[key]
+	if !ok {
+		send = &idempotentSend{
+			done:    make(chan struct{}),
+			expires: now.Add(idempotencyWindow),
+		}
+		client.idempotentSends[key] = send
+
+		// Not bound to ctx, so the result is kept even if the caller gives up.
+		go client.sendRemembered(key, req, send)
+	}
+	client.idempotentMu.Unlock()
+
+	wait := time.NewTimer(client.requestTimeout(req.Type) + client.sendPatchTimeout)
+	defer wait.Stop()
+
+	select {
+	case <-send.done:
+		return send.response, send.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-wait.C:
+		return nil, ErrSendPending
+	case <-client.closed:
+		return nil, ErrClientStopped
+	}
+}
+
+// sendRemembered sends the request of the idempotency key, waiting for the response and the sent message
+// during the whole window, since a late response still means the message is sent.
+func (client *Client) sendRemembered(key string, req Request, send *idempotentSend) {
+	defer close(send.done)
+
+	started, err := client.startRequest(context.Background(), req)
+	if err == nil {
+		started.catchTimeout = time.Until(send.expires)
+		started.patchTimeout = started.catchTimeout
+		send.response, send.err = client.finishSend(context.Background(), req, started)
+	} else {
+		send.err = err
+	}
+
+	var respErr ResponseError
+	if errors.As(send.err, &respErr) {
+		// The message isn't sent, so it may be sent again.
+		client.idempotentMu.Lock()
+		if client.idempotentSends[key] == send {
+			delete(client.idempotentSends, key)
+		}
+		client.idempotentMu.Unlock()
+	}
+}
+
+// SendText sends a plain text message.
+func (client *Client) SendText(ctx context.Context, chatId int64, text string) (*Message, error) {
+	message, err := requestAs[*Message](ctx, client, "sendMessage", &SendMessageRequest{
+		ChatId: chatId,
+		InputMessageContent: &InputMessageText{
+			Text: &FormattedText{
+				Text: text,
+			},
+		},
+	})
+
+	return message, err
+}
+
+// Media types differing from the content type name.
+var mediaTypeNames = map[string]string{
+	TypeMessageVoiceNote: "voice",
+}
+
+// MediaType returns the message content type in snake case without "message" prefix,
+// e.g. "photo", "video_note", "text", and "voice" for voice notes.
+func MediaType(msg *Message) string {
+	if msg == nil || msg.Content == nil {
+		return ""
+	}
+
+	contentType := msg.Content.MessageContentType()
+	if name, ok := mediaTypeNames[contentType]; ok {
+		return name
+	}
+
+	var name strings.Builder
+	for i, r := range strings.TrimPrefix(contentType, "message") {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+
+	return name.String()
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testMessage(id int64, sendingState string) string {
+	state := ""
+	if sendingState != "" {
+		state = fmt.Sprintf(`"sending_state":{"@type":%q},`, sendingState)
+	}
+
+	return fmt.Sprintf(`{"@type":"message","id":%d,"chat_id":1,%s`+
+		`"reply_to":{"@type":"messageReplyToMessage","chat_id":1,"message_id":100},`+
+		`"content":{"@type":"messageText","text":{"@type":"formattedText","text":"hi"}}}`, id, state)
+}
+
+// sendingClient answers sendMessage with a pending message of id 100, replying to the message with the same id,
+// and reports the message sent with id 200 by update.
+func sendingClient(update func(message string) string, options ...Option) *Client {
+	var client *Client
+	client, _ = newMockClient(func(req map[string]interface{}) []string {
+		if req["@type"] != "sendMessage" {
+			return nil
+		}
+
+		go func() {
+			// updateMessageSendSucceeded follows the response
+			time.Sleep(20 * time.Millisecond)
+			_ = client.FeedUpdate([]byte(update(testMessage(200, ""))))
+		}()
+
+		return []string{withExtra(testMessage(100, "messageSendingStatePending"), req["@extra"])}
+	}, options...)
+
+	return client
+}
+
+func TestSendMessagePatch(t *testing.T) {
+	var marshaled int32
+	client := sendingClient(func(message string) string {
+		return fmt.Sprintf(`{"@type":"updateMessageSendSucceeded","message":%s,"old_message_id":100}`, message)
+	}, WithJSONCodec(func(v interface{}) ([]byte, error) {
+		if _, ok := v.(*Message); ok {
+			atomic.AddInt32(&marshaled, 1)
+		}
+		return json.Marshal(v)
+	}, json.Unmarshal))
+	defer client.Stop()
+
+	message, err := client.SendMessage(&SendMessageRequest{
+		ChatId: 1,
+		InputMessageContent: &InputMessageText{
+			Text: &FormattedText{
+				Text: "hi",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.Id != 200 {
+		t.Fatalf("expected real id 200, got %d", message.Id)
+	}
+	if message.SendingState != nil {
+		t.Fatalf("unexpected sending state %T", message.SendingState)
+	}
+	replyTo, ok := message.ReplyTo.(*MessageReplyToMessage)
+	if !ok || replyTo.MessageId != 100 {
+		t.Fatalf("reply_to is changed: %+v", message.ReplyTo)
+	}
+	if atomic.LoadInt32(&marshaled) != 1 {
+		t.Fatal("patched message isn't encoded by the client codec")
+	}
+}
+
+func TestSendMessageFailed(t *testing.T) {
+	client := sendingClient(func(string) string {
+		return fmt.Sprintf(`{"@type":"updateMessageSendFailed","message":%s,"old_message_id":100,`+
+			`"error":{"@type":"error","code":400,"message":"PEER_FLOOD"}}`, testMessage(100, "messageSendingStateFailed"))
+	})
+	defer client.Stop()
+
+	response, err := client.Send(Request{
+		meta: meta{
+			Type: "sendMessage",
+		},
+		Data: map[string]interface{}{
+			"chat_id": 1,
+		},
+	})
+
+	var tdErr *Error
+	if !errors.As(err, &tdErr) || tdErr.Code != 400 || tdErr.Message != "PEER_FLOOD" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	message, err := UnmarshalMessage(response.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := message.SendingState.(*MessageSendingStateFailed); !ok {
+		t.Fatalf("unexpected sending state %T", message.SendingState)
+	}
+}
+
+func TestSendTextIdempotentAfterTimeout(t *testing.T) {
+	client, transport := newMockClient(nil, WithCatchTimeout(30*time.Millisecond), WithSendPatchTimeout(10*time.Millisecond))
+	defer client.Stop()
+
+	ctx := WithIdempotencyKey(context.Background(), "greeting")
+	if _, err := client.SendText(ctx, 1, "hi"); !errors.Is(err, ErrSendPending) {
+		t.Fatalf("expected ErrSendPending, got %v", err)
+	}
+
+	// TDLib has sent the message after all.
+	sent := transport.requests()
+	if len(sent) != 1 {
+		t.Fatalf("unexpected requests %v", sent)
+	}
+	feed(t, client, withExtra(testMessage(100, "messageSendingStatePending"), sent[0]["@extra"]))
+	// updateMessageSendSucceeded follows the response
+	time.Sleep(20 * time.Millisecond)
+	feed(t, client, fmt.Sprintf(`{"@type":"updateMessageSendSucceeded","message":%s,"old_message_id":100}`, testMessage(200, "")))
+
+	message, err := client.SendText(ctx, 1, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Id != 200 {
+		t.Fatalf("expected the sent message 200, got %d", message.Id)
+	}
+	if sent := transport.requests(); len(sent) != 1 {
+		t.Fatalf("message is sent again: %v", sent)
+	}
+}
+
+func TestSendTextIdempotentForgetsFailure(t *testing.T) {
+	client, transport := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"error","code":400,"message":"Bad Request: chat not found"}`, req["@extra"])}
+	})
+	defer client.Stop()
+
+	ctx := WithIdempotencyKey(context.Background(), "greeting")
+	for i := 0; i < 2; i++ {
+		var respErr ResponseError
+		if _, err := client.SendText(ctx, 1, "hi"); !errors.As(err, &respErr) {
+			t.Fatalf("expected TDLib error, got %v", err)
+		}
+	}
+
+	if sent := transport.requests(); len(sent) != 2 {
+		t.Fatalf("failed message isn't sent again: %v", sent)
+	}
+}
+
+func TestSendAlbumSize(t *testing.T) {
+	client, transport := newMockClient(nil)
+	defer client.Stop()
+
+	ctx := context.Background()
+	if _, err := client.SendAlbum(ctx, 1, []string{"a.jpg"}, nil); err == nil {
+		t.Fatal("album of a single item is sent")
+	}
+	if _, err := client.SendAlbum(ctx, 1, make([]string, maxAlbumSize+1), nil); !errors.Is(err, ErrAlbumTooLarge) {
+		t.Fatalf("expected ErrAlbumTooLarge, got %v", err)
+	}
+	if sent := transport.requests(); len(sent) != 0 {
+		t.Fatalf("unexpected requests %v", sent)
+	}
+}
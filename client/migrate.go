@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+var (
+	migrationErrorRegexp = regexp.MustCompile(`(?i)migrate|upgraded to a supergroup`)
+	migrationChatRegexp  = regexp.MustCompile(`-100\d+`)
+)
+
+func isMigrationError(respErr *Error) bool {
+	return respErr.Code == 400 && migrationErrorRegexp.MatchString(respErr.Message)
+}
+
+// ResolveMigration reports whether err is caused by the basic group upgraded to a supergroup
+// and returns the new chat id if the error carries it.
+func ResolveMigration(err error) (newChatId int64, ok bool) {
+	var respErr ResponseError
+	if !errors.As(err, &respErr) || !isMigrationError(respErr.Err) {
+		return 0, false
+	}
+
+	newChatId, _ = strconv.ParseInt(migrationChatRegexp.FindString(respErr.Err.Message), 10, 64)
+
+	return newChatId, true
+}
+
+// migratedChatId returns id of the supergroup chat the basic group chat was upgraded to.
+func (client *Client) migratedChatId(chatId int64) (int64, bool) {
+	chat, err := client.GetChat(&GetChatRequest{
+		ChatId: chatId,
+	})
+	if err != nil {
+		return 0, false
+	}
+
+	chatType, ok := chat.Type.(*ChatTypeBasicGroup)
+	if !ok {
+		return 0, false
+	}
+
+	basicGroup, err := client.GetBasicGroup(&GetBasicGroupRequest{
+		BasicGroupId: chatType.BasicGroupId,
+	})
+	if err != nil || basicGroup.UpgradedToSupergroupId == 0 {
+		return 0, false
+	}
+
+	supergroupChat, err := client.CreateSupergroupChat(&CreateSupergroupChatRequest{
+		SupergroupId: basicGroup.UpgradedToSupergroupId,
+	})
+	if err != nil {
+		return 0, false
+	}
+
+	return supergroupChat.Id, true
+}
+
+// requestChatId returns chat_id of the request, whether it's set directly or decoded from the request JSON.
+func requestChatId(req Request) (int64, bool) {
+	switch chatId := req.Data["chat_id"].(type) {
+	case int64:
+		return chatId, true
+	case json.Number:
+		id, err := chatId.Int64()
+		return id, err == nil
+	case float64:
+		return int64(chatId), true
+	}
+
+	return 0, false
+}
+
+// migrateRequest returns a copy of the request targeting the new supergroup chat, see WithAutoMigrate.
+func (client *Client) migrateRequest(req Request, response *Response) (Request, bool) {
+	chatId, ok := requestChatId(req)
+	if !ok {
+		return req, false
+	}
+
+	newChatId, ok := ResolveMigration(buildResponseError(response.Data))
+	if !ok {
+		return req, false
+	}
+	if newChatId == 0 {
+		if newChatId, ok = client.migratedChatId(chatId); !ok {
+			return req, false
+		}
+	}
+
+	data := make(map[string]interface{}, len(req.Data))
+	for key, value := range req.Data {
+		data[key] = value
+	}
+	data["chat_id"] = newChatId
+
+	req.Data = data
+	req.Extra = client.extraGenerator()
+
+	return req, true
+}
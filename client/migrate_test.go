@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestAutoMigrateHelper(t *testing.T) {
+	client, transport := newMockClient(func(req map[string]interface{}) []string {
+		if fmt.Sprint(req["chat_id"]) == "-123" {
+			return []string{withExtra(`{"@type":"error","code":400,"message":"Chat migrated to a supergroup -100777"}`, req["@extra"])}
+		}
+		return []string{withExtra(`{"@type":"messages","total_count":0,"messages":[]}`, req["@extra"])}
+	}, WithAutoMigrate())
+	defer client.Stop()
+
+	if _, err := client.ScheduledMessages(context.Background(), -123); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := transport.requests()
+	if len(sent) != 2 || fmt.Sprint(sent[1]["chat_id"]) != "-100777" {
+		t.Fatalf("request isn't resent to the supergroup: %v", sent)
+	}
+}
+
+func TestRequestChatId(t *testing.T) {
+	for _, value := range []interface{}{int64(-100777), float64(-100777), json.Number("-100777")} {
+		req := Request{
+			Data: map[string]interface{}{
+				"chat_id": value,
+			},
+		}
+
+		chatId, ok := requestChatId(req)
+		if !ok || chatId != -100777 {
+			t.Errorf("unexpected chat id %d of %T", chatId, value)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockTransport records requests of a test client and feeds back the responses returned by respond.
+type mockTransport struct {
+	mu      sync.Mutex
+	client  *Client
+	sent    []map[string]interface{}
+	respond func(req map[string]interface{}) []string
+}
+
+// newMockClient creates a test client sending its requests to a mockTransport.
+func newMockClient(respond func(req map[string]interface{}) []string, options ...Option) (*Client, *mockTransport) {
+	transport := &mockTransport{
+		respond: respond,
+	}
+	client := NewTestClient(append([]Option{WithJsonClient(transport)}, options...)...)
+
+	transport.mu.Lock()
+	transport.client = client
+	transport.mu.Unlock()
+
+	return client, transport
+}
+
+func (transport *mockTransport) Send(req Request) {
+	data, _ := json.Marshal(req)
+
+	var decoded map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	_ = decoder.Decode(&decoded)
+
+	transport.mu.Lock()
+	transport.sent = append(transport.sent, decoded)
+	client := transport.client
+	respond := transport.respond
+	transport.mu.Unlock()
+
+	if respond == nil {
+		return
+	}
+	for _, response := range respond(decoded) {
+		_ = client.FeedUpdate([]byte(response))
+	}
+}
+
+func (transport *mockTransport) Receive(timeout time.Duration) (*Response, error) {
+	transport.mu.Lock()
+	client := transport.client
+	transport.mu.Unlock()
+
+	if client == nil {
+		// The client isn't created yet.
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	}
+
+	select {
+	case <-time.After(timeout):
+	case <-client.closed:
+	}
+
+	return nil, nil
+}
+
+func (transport *mockTransport) Execute(req Request) (*Response, error) {
+	return nil, errDiscardTransport
+}
+
+// withExtra adds @extra of the request to the JSON object, so it's delivered as the response to the request.
+func withExtra(object string, extra interface{}) string {
+	return fmt.Sprintf(`%s,"@extra":%q}`, strings.TrimSuffix(object, "}"), extra)
+}
+
+// requests returns the requests sent so far.
+func (transport *mockTransport) requests() []map[string]interface{} {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	return append([]map[string]interface{}{}, transport.sent...)
+}
+
+// queueTransport answers requests by queueing the responses returned by respond, which are received
+// like TDLib responses, e.g. for a client created by NewClient.
+type queueTransport struct {
+	responses chan *Response
+	respond   func(req Request) []string
+}
+
+func newQueueTransport(respond func(req Request) []string) *queueTransport {
+	return &queueTransport{
+		responses: make(chan *Response, 100),
+		respond:   respond,
+	}
+}
+
+func (transport *queueTransport) Send(req Request) {
+	for _, data := range transport.respond(req) {
+		var response Response
+		_ = json.Unmarshal([]byte(data), &response.meta)
+		response.Data = json.RawMessage(data)
+		transport.responses <- &response
+	}
+}
+
+func (transport *queueTransport) Receive(timeout time.Duration) (*Response, error) {
+	select {
+	case response := <-transport.responses:
+		return response, nil
+	case <-time.After(10 * time.Millisecond):
+		return nil, nil
+	}
+}
+
+func (transport *queueTransport) Execute(req Request) (*Response, error) {
+	return nil, errDiscardTransport
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+// waitPending returns the only update kept in the pending queue of the client.
+func waitPending(t *testing.T, client *Client) *Response {
+	t.Helper()
+
+	select {
+	case response := <-client.pendingResp:
+		select {
+		case extra := <-client.pendingResp:
+			t.Fatalf("unexpected pending %s", extra.Type)
+		case <-time.After(50 * time.Millisecond):
+		}
+		return response
+	case <-time.After(time.Second):
+		t.Fatal("no pending update")
+		return nil
+	}
+}
+
+func TestPendingUpdateTypePerClient(t *testing.T) {
+	titles := NewTestClient(WithPendingUpdateType(&UpdateChatTitle{}))
+	defer titles.Stop()
+	unread := NewTestClient(WithPendingUpdateType(&UpdateChatIsMarkedAsUnread{}))
+	defer unread.Stop()
+
+	for _, client := range []*Client{titles, unread} {
+		feed(t, client, chatTitleUpdate(1), chatReadUpdate(2))
+	}
+
+	if response := waitPending(t, titles); response.Type != TypeUpdateChatTitle {
+		t.Fatalf("unexpected pending %s", response.Type)
+	}
+	if response := waitPending(t, unread); response.Type != TypeUpdateChatIsMarkedAsUnread {
+		t.Fatalf("unexpected pending %s", response.Type)
+	}
+}
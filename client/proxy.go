@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+)
+
+// ProxyLink returns the link for sharing the added proxy, e.g. https://t.me/proxy?server=...
+func (client *Client) ProxyLink(ctx context.Context, proxyId int32) (string, error) {
+	link, err := requestAs[*HttpUrl](ctx, client, "getProxyLink", &GetProxyLinkRequest{
+		ProxyId: proxyId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return link.Url, nil
+}
+
+// EnableProxyById makes TDLib connect through the previously added proxy. Only one proxy can be enabled at a time,
+// use DisableProxy to connect directly again.
+func (client *Client) EnableProxyById(ctx context.Context, proxyId int32) error {
+	_, err := client.request(ctx, "enableProxy", &EnableProxyRequest{
+		ProxyId: proxyId,
+	})
+	return err
+}
+
+// RemoveProxyById removes the proxy from the list of added proxies.
+func (client *Client) RemoveProxyById(ctx context.Context, proxyId int32) error {
+	_, err := client.request(ctx, "removeProxy", &RemoveProxyRequest{
+		ProxyId: proxyId,
+	})
+	return err
+}
+
+// ListProxies returns all added proxies, the enabled one has IsEnabled set.
+func (client *Client) ListProxies(ctx context.Context) ([]*Proxy, error) {
+	proxies, err := requestAs[*Proxies](ctx, client, "getProxies", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return proxies.Proxies, nil
+}
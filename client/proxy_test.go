@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// proxyClient answers getProxies with a single enabled proxy and other requests with ok.
+func proxyClient() (*Client, *mockTransport) {
+	return newMockClient(func(req map[string]interface{}) []string {
+		if req["@type"] == "getProxies" {
+			return []string{withExtra(`{"@type":"proxies","proxies":[{"@type":"proxy","id":7,"server":"127.0.0.1",`+
+				`"port":1080,"is_enabled":true,"type":{"@type":"proxyTypeSocks5"}}]}`, req["@extra"])}
+		}
+
+		return []string{withExtra(`{"@type":"ok"}`, req["@extra"])}
+	})
+}
+
+func TestProxyRequests(t *testing.T) {
+	client, transport := proxyClient()
+	defer client.Stop()
+
+	ctx := context.Background()
+	if err := client.EnableProxyById(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveProxyById(ctx, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := transport.requests()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(sent))
+	}
+
+	want := []map[string]string{
+		{"@type": "enableProxy", "proxy_id": "7"},
+		{"@type": "removeProxy", "proxy_id": "8"},
+	}
+	for i, req := range sent {
+		got := map[string]string{}
+		for key, value := range req {
+			if key != "@extra" {
+				got[key] = fmt.Sprint(value)
+			}
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("request %d: got %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestListProxies(t *testing.T) {
+	client, transport := proxyClient()
+	defer client.Stop()
+
+	proxies, err := client.ListProxies(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proxies) != 1 || proxies[0].Id != 7 || !proxies[0].IsEnabled || proxies[0].Port != 1080 {
+		t.Fatalf("unexpected proxies %+v", proxies)
+	}
+	if _, ok := proxies[0].Type.(*ProxyTypeSocks5); !ok {
+		t.Fatalf("unexpected proxy type %T", proxies[0].Type)
+	}
+
+	if sent := transport.requests(); len(sent) != 1 || sent[0]["@type"] != "getProxies" {
+		t.Fatalf("unexpected requests %v", sent)
+	}
+}
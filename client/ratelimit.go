@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket refilled with rps tokens per second up to burst tokens, see WithRateLimit.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// Read-only once built by WithRateLimit, so it's read without mu.
+	bypass map[string]struct{}
+}
+
+// Send at most rps requests per second with bursts of up to burst requests, requests wait for their turn
+// or their context. All requests share one bucket, except the ones of bypass types, e.g. "getAuthorizationState".
+func WithRateLimit(rps float64, burst int, bypass ...string) Option {
+	return func(client *Client) {
+		if rps <= 0 || burst <= 0 {
+			return
+		}
+
+		limiter := &rateLimiter{
+			rps:    rps,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+			bypass: map[string]struct{}{},
+		}
+		for _, typ := range bypass {
+			limiter.bypass[typ] = struct{}{}
+		}
+		client.rateLimiter = limiter
+	}
+}
+
+// Wait takes a token for the request, waiting until one is available.
+func (limiter *rateLimiter) Wait(ctx context.Context, stopped <-chan struct{}, req Request) error {
+	if _, ok := limiter.bypass[req.Type]; ok {
+		return nil
+	}
+
+	for {
+		limiter.mu.Lock()
+		now := time.Now()
+		limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rps
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+		limiter.last = now
+
+		if limiter.tokens >= 1 {
+			limiter.tokens--
+			limiter.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - limiter.tokens) / limiter.rps * float64(time.Second))
+		limiter.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stopped:
+			return ErrClientStopped
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func okClient(options ...Option) *Client {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"ok"}`, req["@extra"])}
+	}, options...)
+
+	return client
+}
+
+func TestRateLimitSpacesOutSends(t *testing.T) {
+	const (
+		rps   = 20
+		burst = 3
+		extra = 4
+	)
+	client := okClient(WithRateLimit(rps, burst))
+	defer client.Stop()
+
+	start := time.Now()
+	for i := 0; i < burst+extra; i++ {
+		if _, err := client.request(context.Background(), "setOption", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the burst is sent right away, every other request waits for a token
+	want := extra * time.Second / rps
+	if elapsed := time.Since(start); elapsed < want-10*time.Millisecond {
+		t.Fatalf("%d requests took %s, expected at least %s", burst+extra, elapsed, want)
+	}
+}
+
+func TestRateLimitBypass(t *testing.T) {
+	client := okClient(WithRateLimit(1, 1, "getAuthorizationState"))
+	defer client.Stop()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := client.request(context.Background(), "getAuthorizationState", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("bypassed requests took %s", elapsed)
+	}
+}
+
+func TestRateLimitContext(t *testing.T) {
+	client := okClient(WithRateLimit(1, 1))
+	defer client.Stop()
+
+	if _, err := client.request(context.Background(), "setOption", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.request(ctx, "setOption", nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
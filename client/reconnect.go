@@ -0,0 +1,119 @@
+package client
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// First delay between reconnect attempts, doubled after each failed one.
+const reconnectInitialBackoff = time.Second
+
+// Recreate TDLib client and authorize it again once its authorization state becomes closed, e.g. after the session
+// is terminated from another device. Failed attempts are retried with exponential backoff up to maxBackoff.
+// onReconnect, if not nil, is called after each attempt with its error.
+//
+// The authorization handler is run again, so channel based handlers must be fed again; BotTokenAuthorizer keeps
+// its parameters and needs nothing.
+// Close and LogOut requests lead to reconnect too, use Stop to close the client for good.
+func WithAutoReconnect(maxBackoff time.Duration, onReconnect func(attempt int, err error)) Option {
+	return func(client *Client) {
+		client.reconnectMax = maxBackoff
+		client.onReconnect = onReconnect
+	}
+}
+
+// reusableAuthorizer keeps the handler open for the following authorizations.
+type reusableAuthorizer struct {
+	AuthorizationStateHandler
+}
+
+func (reusableAuthorizer) Close() {}
+
+func (client *Client) handleAuthorizationState(update *UpdateAuthorizationState) {
+	if update.AuthorizationState.AuthorizationStateType() != TypeAuthorizationStateClosed {
+		return
+	}
+	// Closed during the first authorization or by Stop. Injected transports aren't recreated.
+	if atomic.LoadInt32(&client.authorized) == 0 || !client.IsRunning() || client.JsonClient() == nil {
+		return
+	}
+
+	go client.reconnect()
+}
+
+func (client *Client) reconnect() {
+	if !atomic.CompareAndSwapInt32(&client.reconnecting, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&client.reconnecting, 0)
+
+	client.failCatchers("TDLib client is closed")
+
+	backoff := reconnectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		if !client.IsRunning() {
+			return
+		}
+
+		client.replaceJsonClient()
+		err := Authorize(client, client.authorizer)
+		if client.onReconnect != nil {
+			client.onReconnect(attempt, err)
+		}
+		if err == nil {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-client.done:
+			return
+		}
+
+		backoff *= 2
+		if backoff > client.reconnectMax {
+			backoff = client.reconnectMax
+		}
+	}
+}
+
+// replaceJsonClient creates a new TDLib client instead of the closed one, keeping its JSON codec.
+func (client *Client) replaceJsonClient() {
+	client.transportMu.Lock()
+	old := client.jsonClient
+	jsonClient := NewJsonClient()
+	jsonClient.marshal = old.marshal
+	jsonClient.unmarshal = old.unmarshal
+	client.jsonClient = jsonClient
+	client.transport = jsonClient
+	client.transportMu.Unlock()
+
+	tdlibInstance.removeClient(old.id)
+	tdlibInstance.addClient(client)
+}
+
+// failCatchers answers requests waiting for response with an error, since the closed TDLib client never responds.
+func (client *Client) failCatchers(message string) {
+	data, _ := json.Marshal(&Error{
+		Code:    500,
+		Message: message,
+	})
+
+	client.catchersStore.Range(func(key, value interface{}) bool {
+		response := &Response{
+			meta: meta{
+				Type:  "error",
+				Extra: key.(string),
+			},
+			Data: data,
+		}
+
+		select {
+		case value.(chan *Response) <- response:
+		default:
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Matches "Too Many Requests: retry after 5" and "FLOOD_WAIT_5" error messages.
+var floodWaitRegexp = regexp.MustCompile(`(?i)(?:retry after |FLOOD_WAIT_)(\d+)`)
+
+// floodWaitSeconds returns the number of seconds to wait before retrying the request failed with the error.
+func floodWaitSeconds(respErr *Error) (int, bool) {
+	if respErr.Code != 429 {
+		return 0, false
+	}
+
+	match := floodWaitRegexp.FindStringSubmatch(respErr.Message)
+	if match == nil {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return seconds, true
+}
+
+// IsFloodWait reports whether the request failed with FLOOD_WAIT error and how many seconds to wait before retrying it.
+func IsFloodWait(err error) (int, bool) {
+	var respErr *Error
+	if !errors.As(err, &respErr) {
+		return 0, false
+	}
+
+	return floodWaitSeconds(respErr)
+}
+
+// Resend requests failed with FLOOD_WAIT error up to attempts times, after waiting for the time requested by Telegram.
+// If the requested wait is longer than maxWait, the error is returned right away.
+func WithFloodWaitRetry(attempts int, maxWait time.Duration) Option {
+	return func(client *Client) {
+		client.floodWaitRetries = attempts
+		client.floodWaitMax = maxWait
+	}
+}
+
+// retryFloodWait resends the request while it fails with FLOOD_WAIT error, see WithFloodWaitRetry.
+func (client *Client) retryFloodWait(ctx context.Context, req Request, response *Response) (*Response, error) {
+	for attempt := 0; attempt < client.floodWaitRetries && response.Type == "error"; attempt++ {
+		respErr, err := UnmarshalError(response.Data)
+		if err != nil {
+			return response, nil
+		}
+
+		seconds, ok := floodWaitSeconds(respErr)
+		wait := time.Duration(seconds) * time.Second
+		if !ok || wait > client.floodWaitMax {
+			return response, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		req.Extra = client.extraGenerator()
+		response, err = client.sendOnce(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
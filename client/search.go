@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+)
+
+// SearchPublic searches public chats by looking for specified query in their username and title.
+func (client *Client) SearchPublic(ctx context.Context, query string) ([]*Chat, error) {
+	found, err := requestAs[*Chats](ctx, client, "searchPublicChats", &SearchPublicChatsRequest{
+		Query: query,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chats := make([]*Chat, 0, len(found.ChatIds))
+	for _, chatId := range found.ChatIds {
+		chat, err := requestAs[*Chat](ctx, client, "getChat", &GetChatRequest{
+			ChatId: chatId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, nil
+}
+
+// SearchMessagesGlobal searches messages in all chats of the main chat list,
+// following next_offset of the results until limit messages are found.
+func (client *Client) SearchMessagesGlobal(ctx context.Context, query string, limit int32) ([]*Message, error) {
+	var messages []*Message
+	var offset string
+	for int32(len(messages)) < limit {
+		pageLimit := limit - int32(len(messages))
+		if pageLimit > maxHistoryPageSize {
+			pageLimit = maxHistoryPageSize
+		}
+
+		found, err := requestAs[*FoundMessages](ctx, client, "searchMessages", &SearchMessagesRequest{
+			ChatList: &ChatListMain{},
+			Query:    query,
+			Offset:   offset,
+			Limit:    pageLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, found.Messages...)
+		if found.NextOffset == "" || len(found.Messages) == 0 {
+			break
+		}
+		offset = found.NextOffset
+	}
+
+	return messages, nil
+}
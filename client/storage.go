@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Optimize deletes cached files until their total size is at most maxSize bytes and removes files
+// not accessed for maxAge. Pass 0 to use TDLib default limits. Returns statistics of the deleted files.
+func (client *Client) Optimize(ctx context.Context, maxSize int64, maxAge time.Duration) (*StorageStatistics, error) {
+	size := int64(-1)
+	if maxSize > 0 {
+		size = maxSize
+	}
+	ttl := int32(-1)
+	if maxAge > 0 {
+		ttl = int32(maxAge / time.Second)
+	}
+
+	statistics, err := requestAs[*StorageStatistics](ctx, client, "optimizeStorage", &OptimizeStorageRequest{
+		Size:                        size,
+		Ttl:                         ttl,
+		Count:                       -1,
+		ImmunityDelay:               -1,
+		ReturnDeletedFileStatistics: true,
+	})
+
+	return statistics, err
+}
+
+// StorageStats returns detailed storage usage, with separate entries for up to chatLimit chats using most space.
+func (client *Client) StorageStats(ctx context.Context, chatLimit int32) (*StorageStatistics, error) {
+	statistics, err := requestAs[*StorageStatistics](ctx, client, "getStorageStatistics", &GetStorageStatisticsRequest{
+		ChatLimit: chatLimit,
+	})
+
+	return statistics, err
+}
+
+// StorageStatsFast quickly returns approximate total storage usage.
+func (client *Client) StorageStatsFast(ctx context.Context) (*StorageStatisticsFast, error) {
+	statistics, err := requestAs[*StorageStatisticsFast](ctx, client, "getStorageStatisticsFast", nil)
+
+	return statistics, err
+}
@@ -9,6 +9,7 @@ package client
 //#include <stdlib.h>
 //#include <td/telegram/td_json_client.h>
 //#include <td/telegram/td_log.h>
+//extern void goTdlibLogMessage(int verbosityLevel, char *message);
 import "C"
 
 import (
@@ -24,57 +25,212 @@ import (
 
 var tdlibInstance *tdlib
 
+var errReceiveTimeout = errors.New("update receiving timeout")
+
 func init() {
 	tdlibInstance = &tdlib{
 		timeout: 60 * time.Second,
 		clients: map[int]*Client{},
+		removed: map[int]struct{}{},
+	}
+}
+
+var (
+	fatalLogOnce sync.Once
+	fatalLogMu   sync.Mutex
+	// Watchers of clients being started by NewClient with WithFailOnFatalLog.
+	fatalLogWatchers = map[*fatalLogWatcher]struct{}{}
+)
+
+// fatalLogWatcher receives TDLib fatal errors until released by NewClient.
+type fatalLogWatcher struct {
+	fatal    chan string
+	released chan struct{}
+	once     sync.Once
+}
+
+func (watcher *fatalLogWatcher) release() {
+	watcher.once.Do(func() {
+		close(watcher.released)
+	})
+}
+
+//export goTdlibLogMessage
+func goTdlibLogMessage(verbosityLevel C.int, message *C.char) {
+	if verbosityLevel != 0 {
+		return
+	}
+
+	fatal := C.GoString(message)
+
+	fatalLogMu.Lock()
+	watchers := make([]*fatalLogWatcher, 0, len(fatalLogWatchers))
+	for watcher := range fatalLogWatchers {
+		select {
+		case watcher.fatal <- fatal:
+		default:
+		}
+		watchers = append(watchers, watcher)
+	}
+	fatalLogMu.Unlock()
+
+	// TDLib aborts the process as soon as the callback returns on a fatal error,
+	// so keep its thread waiting until NewClient has returned the error.
+	for _, watcher := range watchers {
+		<-watcher.released
 	}
 }
 
+// watchFatalLog reports TDLib fatal errors to the returned channel until stop is called,
+// the fatal log callback doesn't return meanwhile.
+func watchFatalLog() (fatal <-chan string, stop func()) {
+	fatalLogOnce.Do(func() {
+		// It replaces the callback set by td_set_log_message_callback for the whole process,
+		// messages are still written to the TDLib log stream.
+		C.td_set_log_message_callback(0, (C.td_log_message_callback_ptr)(C.goTdlibLogMessage))
+	})
+
+	watcher := &fatalLogWatcher{
+		fatal:    make(chan string, 1),
+		released: make(chan struct{}),
+	}
+
+	fatalLogMu.Lock()
+	fatalLogWatchers[watcher] = struct{}{}
+	fatalLogMu.Unlock()
+
+	return watcher.fatal, func() {
+		fatalLogMu.Lock()
+		delete(fatalLogWatchers, watcher)
+		fatalLogMu.Unlock()
+
+		watcher.release()
+	}
+}
+
+// receiveBackoff is the wait between failed receives, doubled after every failure up to max.
+type receiveBackoff struct {
+	initial time.Duration
+	max     time.Duration
+	next    time.Duration
+}
+
+// failed returns how long to wait after a failed receive.
+func (backoff *receiveBackoff) failed() time.Duration {
+	if backoff.next < backoff.initial {
+		backoff.next = backoff.initial
+	}
+	wait := backoff.next
+
+	backoff.next *= 2
+	if backoff.next > backoff.max {
+		backoff.next = backoff.max
+	}
+
+	return wait
+}
+
+// reset starts over from initial after a successful receive.
+func (backoff *receiveBackoff) reset() {
+	backoff.next = 0
+}
+
 type tdlib struct {
 	once    sync.Once
 	timeout time.Duration
 	mu      sync.Mutex
 	clients map[int]*Client
+	// Ids of stopped or replaced clients, whose late responses are dropped silently.
+	removed map[int]struct{}
 }
 
 func (instance *tdlib) addClient(client *Client) {
 	instance.mu.Lock()
 	defer instance.mu.Unlock()
 
-	instance.clients[client.jsonClient.id] = client
+	instance.clients[client.JsonClient().id] = client
 
 	instance.once.Do(func() {
 		go instance.receiver()
 	})
 }
 
+func (instance *tdlib) removeClient(id int) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	delete(instance.clients, id)
+	instance.removed[id] = struct{}{}
+}
+
+// getClient returns the client with the TDLib id, nil without error if it's removed already.
 func (instance *tdlib) getClient(id int) (*Client, error) {
 	instance.mu.Lock()
 	defer instance.mu.Unlock()
 
 	client, ok := instance.clients[id]
 	if !ok {
+		if _, removed := instance.removed[id]; removed {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("client [id: %d] does not exist", id)
 	}
 
 	return client, nil
 }
 
+// receiveErrors reports the error to handlers of the clients and returns the longest backoff of them.
+func (instance *tdlib) receiveErrors(err error) (initial, max time.Duration) {
+	instance.mu.Lock()
+	var handlers []func(err error)
+	for _, client := range instance.clients {
+		if client.receiveErrHandler != nil {
+			handlers = append(handlers, client.receiveErrHandler)
+		}
+		if client.receiveBackoff > initial {
+			initial = client.receiveBackoff
+		}
+		if client.receiveBackoffMax > max {
+			max = client.receiveBackoffMax
+		}
+	}
+	instance.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(err)
+	}
+
+	return initial, max
+}
+
 func (instance *tdlib) receiver() {
+	wait := &receiveBackoff{}
 	for {
 		resp, err := instance.receive(instance.timeout)
+		if err == errReceiveTimeout {
+			continue
+		}
 		if err != nil {
+			wait.initial, wait.max = instance.receiveErrors(err)
+			time.Sleep(wait.failed())
 			continue
 		}
+		wait.reset()
 
 		client, err := instance.getClient(resp.ClientId)
 		if err != nil {
 			log.Print(err)
 			continue
 		}
+		if client == nil {
+			continue
+		}
 
-		client.responses <- resp
+		client.waitBackpressure()
+		select {
+		case client.responses <- resp:
+		case <-client.closed:
+		}
 	}
 }
 
@@ -85,7 +241,7 @@ func (instance *tdlib) receiver() {
 func (instance *tdlib) receive(timeout time.Duration) (*Response, error) {
 	result := C.td_receive(C.double(float64(timeout) / float64(time.Second)))
 	if result == nil {
-		return nil, errors.New("update receiving timeout")
+		return nil, errReceiveTimeout
 	}
 
 	data := []byte(C.GoString(result))
@@ -127,18 +283,22 @@ func Execute(req Request) (*Response, error) {
 }
 
 type JsonClient struct {
-	id int
+	id        int
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
 }
 
 func NewJsonClient() *JsonClient {
 	return &JsonClient{
-		id: int(C.td_create_client_id()),
+		id:        int(C.td_create_client_id()),
+		marshal:   json.Marshal,
+		unmarshal: json.Unmarshal,
 	}
 }
 
 // Sends request to the TDLib client. May be called from any thread.
 func (jsonClient *JsonClient) Send(req Request) {
-	data, _ := json.Marshal(req)
+	data, _ := jsonClient.marshal(req)
 
 	query := C.CString(string(data))
 	defer C.free(unsafe.Pointer(query))
@@ -146,12 +306,45 @@ func (jsonClient *JsonClient) Send(req Request) {
 	C.td_send(C.int(jsonClient.id), query)
 }
 
+// Receives incoming updates and request responses of all TDLib clients.
+// The package already receives responses in background and dispatches them to clients,
+// so anything received here won't reach Client listeners and catchers, including those of a JsonClient
+// passed to WithJsonClient.
+func (jsonClient *JsonClient) Receive(timeout time.Duration) (*Response, error) {
+	return tdlibInstance.receive(timeout)
+}
+
+// Returns TDLib client identifier, which is set to @client_id field of its responses.
+func (jsonClient *JsonClient) Id() int {
+	return jsonClient.id
+}
+
 // Synchronously executes TDLib request. May be called from any thread.
 // Only a few requests can be executed synchronously.
 // Returned pointer will be deallocated by TDLib during next call to td_json_client_receive or td_json_client_execute
 // in the same thread, so it can't be used after that.
 func (jsonClient *JsonClient) Execute(req Request) (*Response, error) {
-	return Execute(req)
+	data, _ := jsonClient.marshal(req)
+
+	query := C.CString(string(data))
+	defer C.free(unsafe.Pointer(query))
+	result := C.td_execute(query)
+	if result == nil {
+		return nil, errors.New("request can't be parsed")
+	}
+
+	data = []byte(C.GoString(result))
+
+	var resp Response
+
+	err := jsonClient.unmarshal(data, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Data = data
+
+	return &resp, nil
 }
 
 type meta struct {
@@ -163,6 +356,8 @@ type meta struct {
 type Request struct {
 	meta
 	Data map[string]interface{}
+
+	blocked error
 }
 
 func (req Request) MarshalJSON() ([]byte, error) {
@@ -185,6 +380,15 @@ func (responseError ResponseError) Error() string {
 	return fmt.Sprintf("%d %s", responseError.Err.Code, responseError.Err.Message)
 }
 
+// Unwrap allows errors.As(err, &tdErr) with tdErr of *Error type.
+func (responseError ResponseError) Unwrap() error {
+	return responseError.Err
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%d %s", err.Code, err.Message)
+}
+
 func buildResponseError(data json.RawMessage) error {
 	respErr, err := UnmarshalError(data)
 	if err != nil {
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReceiveBackoff(t *testing.T) {
+	backoff := &receiveBackoff{
+		initial: 10 * time.Millisecond,
+		max:     40 * time.Millisecond,
+	}
+
+	for _, want := range []time.Duration{10, 20, 40, 40} {
+		if got := backoff.failed(); got != want*time.Millisecond {
+			t.Fatalf("got %s, want %s", got, want*time.Millisecond)
+		}
+	}
+
+	backoff.reset()
+	if got := backoff.failed(); got != 10*time.Millisecond {
+		t.Fatalf("got %s after reset", got)
+	}
+}
+
+func TestWithReceiveBackoffClampsMax(t *testing.T) {
+	client := &Client{}
+	WithReceiveBackoff(50*time.Millisecond, time.Millisecond)(client)
+
+	backoff := &receiveBackoff{
+		initial: client.receiveBackoff,
+		max:     client.receiveBackoffMax,
+	}
+	for i := 0; i < 3; i++ {
+		if got := backoff.failed(); got != 50*time.Millisecond {
+			t.Fatalf("attempt %d: got %s", i, got)
+		}
+	}
+}
+
+// failingTransport fails every receive right away.
+type failingTransport struct {
+	mockTransport
+}
+
+var errReceiveFailed = errors.New("receive failed")
+
+func (transport *failingTransport) Receive(timeout time.Duration) (*Response, error) {
+	return nil, errReceiveFailed
+}
+
+func TestReceiveErrorHandler(t *testing.T) {
+	var failures int32
+	client := NewTestClient(
+		WithJsonClient(&failingTransport{}),
+		WithReceiveBackoff(20*time.Millisecond, 20*time.Millisecond),
+		WithReceiveErrorHandler(func(err error) {
+			if !errors.Is(err, errReceiveFailed) {
+				t.Errorf("unexpected error %v", err)
+			}
+			atomic.AddInt32(&failures, 1)
+		}),
+	)
+	defer client.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 5 receives are expected, far fewer than without backoff
+	if n := atomic.LoadInt32(&failures); n < 2 || n > 10 {
+		t.Fatalf("unexpected number of failed receives %d", n)
+	}
+}
+
+func TestRemovedClient(t *testing.T) {
+	instance := &tdlib{
+		clients: map[int]*Client{1: {}},
+		removed: map[int]struct{}{},
+	}
+
+	instance.removeClient(1)
+	if client, err := instance.getClient(1); client != nil || err != nil {
+		t.Fatalf("removed client: got %v, %v", client, err)
+	}
+	if _, err := instance.getClient(2); err == nil {
+		t.Fatal("unknown client isn't an error")
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Transport sends requests to TDLib and receives its responses, implemented by JsonClient.
+// A nil response with nil error returned by Receive is a timeout, any error is a failed receive, see WithReceiveBackoff.
+// See WithJsonClient.
+type Transport interface {
+	Send(req Request)
+	Receive(timeout time.Duration) (*Response, error)
+	Execute(req Request) (*Response, error)
+}
+
+// How long receiveTransport waits for a response of an injected transport at once.
+const transportReceiveTimeout = time.Second
+
+// receiveTransport receives responses of the transport passed to WithJsonClient, since only TDLib clients
+// are received by the package receiver.
+func (client *Client) receiveTransport() {
+	wait := &receiveBackoff{
+		initial: client.receiveBackoff,
+		max:     client.receiveBackoffMax,
+	}
+	for {
+		select {
+		case <-client.closed:
+			return
+		default:
+		}
+
+		resp, err := client.currentTransport().Receive(transportReceiveTimeout)
+		if resp == nil && err == nil {
+			continue
+		}
+		if err != nil {
+			if client.receiveErrHandler != nil {
+				client.receiveErrHandler(err)
+			}
+
+			select {
+			case <-time.After(wait.failed()):
+			case <-client.closed:
+				return
+			}
+			continue
+		}
+		wait.reset()
+
+		client.waitBackpressure()
+		select {
+		case client.responses <- resp:
+		case <-client.closed:
+			return
+		}
+	}
+}
+
+var errDiscardTransport = errors.New("test client has no TDLib instance")
+
+// discardTransport drops requests of a test client.
+type discardTransport struct {
+	closed <-chan struct{}
+}
+
+func (discardTransport) Send(req Request) {}
+
+func (transport discardTransport) Receive(timeout time.Duration) (*Response, error) {
+	select {
+	case <-time.After(timeout):
+	case <-transport.closed:
+	}
+	return nil, nil
+}
+
+func (discardTransport) Execute(req Request) (*Response, error) {
+	return nil, errDiscardTransport
+}
+
+// NewTestClient creates a client which isn't backed by TDLib, e.g. to test update handlers.
+// Updates and responses are pushed by FeedUpdate. Requests are never answered unless a response
+// with the same @extra is fed, so use WithExtraGenerator to know extras in advance.
+func NewTestClient(options ...Option) *Client {
+	client := newClient(options, func(client *Client) Transport {
+		return discardTransport{
+			closed: client.closed,
+		}
+	}, "test")
+	client.start()
+
+	return client
+}
+
+// FeedUpdate pushes raw TDLib JSON to the client as if it was received from TDLib.
+// Data with @extra is delivered to the request waiting for it.
+func (client *Client) FeedUpdate(data []byte) error {
+	var resp Response
+
+	err := json.Unmarshal(data, &resp)
+	if err != nil {
+		return err
+	}
+
+	resp.Data = data
+
+	select {
+	case client.responses <- &resp:
+		return nil
+	case <-client.closed:
+		return ErrClientStopped
+	}
+}
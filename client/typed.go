@@ -0,0 +1,89 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SendTyped sends the request and decodes the response into T, e.g.
+//
+//	message, err := SendTyped[Message](client, req)
+//
+// TDLib error is returned as ResponseError, a response of another type than T is an error too.
+func SendTyped[T any, PT interface {
+	*T
+	Type
+}](client *Client, req Request) (*T, error) {
+	response, err := client.Send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := PT(new(T))
+	if response.Type != result.GetType() {
+		return nil, fmt.Errorf("%s: unexpected response type %s, expected %s", req.Type, response.Type, result.GetType())
+	}
+
+	err = client.unmarshalJSON(response.Data, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*T)(result), nil
+}
+
+// request sends the typed request of the TDLib method through SendContext, so cancelling ctx releases
+// the request instead of leaving it to wait for a response in background. The request may be nil for methods
+// without parameters.
+func (client *Client) request(ctx context.Context, method string, req interface{}) (Type, error) {
+	data := map[string]interface{}{}
+	if req != nil {
+		raw, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+
+		// json.Number keeps int64 values intact
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		err = decoder.Decode(&data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := client.SendContext(ctx, Request{
+		meta: meta{
+			Type: method,
+		},
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type == "error" {
+		return nil, buildResponseError(response.Data)
+	}
+
+	return client.unmarshalType(response.Data)
+}
+
+// requestAs works like request, but expects the response to be of T, e.g. requestAs[*Chat] or requestAs[AuthorizationState].
+func requestAs[T any](ctx context.Context, client *Client, method string, req interface{}) (T, error) {
+	var zero T
+
+	result, err := client.request(ctx, method, req)
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := result.(T)
+	if !ok {
+		return zero, fmt.Errorf("%s: unexpected response type %s", method, result.GetType())
+	}
+
+	return typed, nil
+}
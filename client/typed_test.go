@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRequestAs(t *testing.T) {
+	client, transport := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(fmt.Sprintf(`{"@type":"chat","id":%s,"title":"test"}`, req["chat_id"]), req["@extra"])}
+	})
+	defer client.Stop()
+
+	chat, err := requestAs[*Chat](context.Background(), client, "getChat", &GetChatRequest{
+		ChatId: -1001234567890123,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chat.Id != -1001234567890123 || chat.Title != "test" {
+		t.Fatalf("unexpected chat %d %q", chat.Id, chat.Title)
+	}
+
+	sent := transport.requests()
+	if len(sent) != 1 || sent[0]["@type"] != "getChat" || fmt.Sprint(sent[0]["chat_id"]) != "-1001234567890123" {
+		t.Fatalf("unexpected request %v", sent)
+	}
+}
+
+func TestRequestCancelReleasesCatcher(t *testing.T) {
+	client, _ := newMockClient(nil)
+	defer client.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.request(ctx, "getMe", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+
+	client.catchersStore.Range(func(key, value interface{}) bool {
+		t.Errorf("catcher %v is left after cancel", key)
+		return true
+	})
+}
+
+func TestSendTyped(t *testing.T) {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"user","id":42,"first_name":"Test"}`, req["@extra"])}
+	})
+	defer client.Stop()
+
+	user, err := SendTyped[User](client, Request{
+		meta: meta{
+			Type: "getMe",
+		},
+		Data: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != 42 || user.FirstName != "Test" {
+		t.Fatalf("unexpected user %d %q", user.Id, user.FirstName)
+	}
+}
+
+func TestSendTypedError(t *testing.T) {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"error","code":400,"message":"CHAT_NOT_FOUND"}`, req["@extra"])}
+	})
+	defer client.Stop()
+
+	_, err := SendTyped[Chat](client, Request{
+		meta: meta{
+			Type: "getChat",
+		},
+		Data: map[string]interface{}{
+			"chat_id": 1,
+		},
+	})
+
+	var responseErr ResponseError
+	if !errors.As(err, &responseErr) {
+		t.Fatalf("expected ResponseError, got %v", err)
+	}
+	var tdErr *Error
+	if !errors.As(err, &tdErr) || tdErr.Code != 400 || tdErr.Message != "CHAT_NOT_FOUND" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSendTypedUnexpectedType(t *testing.T) {
+	client, _ := newMockClient(func(req map[string]interface{}) []string {
+		return []string{withExtra(`{"@type":"ok"}`, req["@extra"])}
+	})
+	defer client.Stop()
+
+	_, err := SendTyped[Chat](client, Request{
+		meta: meta{
+			Type: "getChat",
+		},
+		Data: map[string]interface{}{},
+	})
+	if err == nil {
+		t.Fatal("response of another type isn't an error")
+	}
+}
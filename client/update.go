@@ -0,0 +1,423 @@
+package client
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscribe feeds every update to handle in a separate goroutine until the returned stop function is called
+// or the client is stopped. handle must give up sending when done is closed, closed is invoked once the goroutine exits.
+// A stopped client doesn't start the goroutine, closed is invoked right away.
+func (client *Client) subscribe(capacity int, handle func(update Type, done <-chan struct{}), closed func()) func() {
+	client.watchersMu.Lock()
+	if !client.IsRunning() {
+		client.watchersMu.Unlock()
+		closed()
+		return func() {}
+	}
+	client.watchers.Add(1)
+	client.watchersMu.Unlock()
+
+	listener := &Listener{
+		isActive:   true,
+		RawUpdates: make(chan Type, capacity),
+		internal:   true,
+	}
+	client.addListener(listener)
+
+	done := make(chan struct{})
+	client.goLabeled("watcher", func() {
+		defer client.watchers.Done()
+		defer closed()
+
+		for {
+			select {
+			case update, ok := <-listener.RawUpdates:
+				if !ok {
+					return
+				}
+				handle(update, done)
+			case <-done:
+				return
+			}
+		}
+	})
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			listener.Close()
+		})
+	}
+
+	go func() {
+		select {
+		case <-client.done:
+			stop()
+		case <-done:
+		}
+	}()
+
+	return stop
+}
+
+// goWatched runs fn in a goroutine Stop waits for, like watchers of subscribe.
+// It returns false without running fn if the client is stopped.
+func (client *Client) goWatched(role string, fn func()) bool {
+	client.watchersMu.Lock()
+	defer client.watchersMu.Unlock()
+
+	if !client.IsRunning() {
+		return false
+	}
+	client.watchers.Add(1)
+
+	client.goLabeled(role, func() {
+		defer client.watchers.Done()
+
+		fn()
+	})
+
+	return true
+}
+
+type ReactionUpdate struct {
+	ChatId    int64
+	MessageId int64
+	Reactions []*MessageReaction
+}
+
+// OnReactions streams reaction changes of messages from both updateMessageReactions and updateMessageInteractionInfo.
+// Call the returned function to stop the stream, the channel is closed afterwards.
+func (client *Client) OnReactions(capacity int) (<-chan ReactionUpdate, func()) {
+	reactions := make(chan ReactionUpdate, capacity)
+
+	stop := client.subscribe(capacity, func(update Type, done <-chan struct{}) {
+		var reaction ReactionUpdate
+
+		switch upd := update.(type) {
+		case *UpdateMessageReactions:
+			reaction = ReactionUpdate{
+				ChatId:    upd.ChatId,
+				MessageId: upd.MessageId,
+				Reactions: upd.Reactions,
+			}
+
+		case *UpdateMessageInteractionInfo:
+			reaction = ReactionUpdate{
+				ChatId:    upd.ChatId,
+				MessageId: upd.MessageId,
+			}
+			if upd.InteractionInfo != nil && upd.InteractionInfo.Reactions != nil {
+				reaction.Reactions = upd.InteractionInfo.Reactions.Reactions
+			}
+
+		default:
+			return
+		}
+
+		select {
+		case reactions <- reaction:
+		case <-done:
+		}
+	}, func() {
+		close(reactions)
+	})
+
+	return reactions, stop
+}
+
+// OnServiceNotification streams service notifications, e.g. security alerts from Telegram.
+// AUTH_KEY_DROP_ notifications are also passed to WithAuthKeyDropHandler handler, or logged without it.
+func (client *Client) OnServiceNotification(capacity int) (<-chan *UpdateServiceNotification, func()) {
+	notifications := make(chan *UpdateServiceNotification, capacity)
+
+	stop := client.subscribe(capacity, func(update Type, done <-chan struct{}) {
+		notification, ok := update.(*UpdateServiceNotification)
+		if !ok {
+			return
+		}
+
+		select {
+		case notifications <- notification:
+		case <-done:
+		}
+	}, func() {
+		close(notifications)
+	})
+
+	return notifications, stop
+}
+
+// OnNewChat streams chats from updateNewChat, sent once per chat when TDLib learns about it.
+// Call the returned function to stop the stream, the channel is closed afterwards.
+func (client *Client) OnNewChat(capacity int) (<-chan *Chat, func()) {
+	chats := make(chan *Chat, capacity)
+
+	stop := client.subscribe(capacity, func(update Type, done <-chan struct{}) {
+		upd, ok := update.(*UpdateNewChat)
+		if !ok {
+			return
+		}
+
+		select {
+		case chats <- upd.Chat:
+		case <-done:
+		}
+	}, func() {
+		close(chats)
+	})
+
+	return chats, stop
+}
+
+func isAuthKeyDrop(notification *UpdateServiceNotification) bool {
+	return strings.HasPrefix(notification.Type, "AUTH_KEY_DROP_")
+}
+
+func (client *Client) handleAuthKeyDrop(notification *UpdateServiceNotification) {
+	if client.authKeyDropHandler == nil {
+		log.Printf("service notification %s: session must be confirmed or logged out", notification.Type)
+		return
+	}
+
+	// The handler may send requests, so don't block the receiver.
+	client.goWatched("auth key drop", func() {
+		client.authKeyDropHandler(notification)
+	})
+}
+
+const resyncChatsLimit = 100
+
+// handleConnectionState reloads chats when the connection is ready again after it was lost.
+// Called from the receiver goroutine only.
+func (client *Client) handleConnectionState(update *UpdateConnectionState) {
+	ready := update.State.ConnectionStateType() == TypeConnectionStateReady
+	reconnected := ready && !client.connectionReady && client.wasReady
+	client.connectionReady = ready
+	if ready {
+		client.wasReady = true
+	}
+
+	if reconnected {
+		go client.resyncChats()
+	}
+}
+
+func (client *Client) resyncChats() {
+	for {
+		select {
+		case <-client.done:
+			return
+		default:
+		}
+
+		// TDLib returns 404 once the whole chat list is loaded.
+		_, err := client.LoadChats(&LoadChatsRequest{
+			ChatList: &ChatListMain{},
+			Limit:    resyncChatsLimit,
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Don't dispatch updateNewMessage of messages older than age while TDLib catches up missed updates on start,
+// e.g. not to greet users who joined hours ago again. TDLib reports connectionStateUpdating while catching up,
+// the window ends on the first connectionStateReady after it.
+func WithIgnoreBacklogOlderThan(age time.Duration) Option {
+	return func(client *Client) {
+		client.ignoreBacklogOlder = age
+	}
+}
+
+// isOldBacklog reports whether the update is an old message received during the catch-up window.
+// Called from the receiver goroutine only.
+func (client *Client) isOldBacklog(update Type) bool {
+	if client.backlogDone {
+		return false
+	}
+
+	switch upd := update.(type) {
+	case *UpdateConnectionState:
+		switch upd.State.ConnectionStateType() {
+		case TypeConnectionStateUpdating:
+			client.backlogUpdating = true
+		case TypeConnectionStateReady:
+			// Ready reported before catching up starts doesn't end the window.
+			client.backlogDone = client.backlogUpdating
+		}
+	case *UpdateNewMessage:
+		sent := time.Unix(int64(upd.Message.Date), 0)
+		return time.Since(sent) > client.ignoreBacklogOlder
+	}
+
+	return false
+}
+
+type updateWaiter struct {
+	found   chan Type
+	stop    func()
+	stopped <-chan struct{}
+}
+
+// waitFor starts watching updates right away, so the awaited update can't be missed
+// while the request triggering it is being sent.
+func (client *Client) waitFor(match func(update Type) bool) *updateWaiter {
+	waiter := &updateWaiter{
+		found:   make(chan Type, 1),
+		stopped: client.done,
+	}
+
+	waiter.stop = client.subscribe(100, func(update Type, done <-chan struct{}) {
+		if !match(update) {
+			return
+		}
+
+		select {
+		case waiter.found <- update:
+		default:
+		}
+	}, func() {})
+
+	return waiter
+}
+
+// Wait returns the first matched update and stops watching.
+func (waiter *updateWaiter) Wait(ctx context.Context) (Type, error) {
+	defer waiter.stop()
+
+	select {
+	case update := <-waiter.found:
+		return update, nil
+	case <-waiter.stopped:
+		return nil, ErrClientStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type DeletedMessages struct {
+	ChatId     int64
+	MessageIds []int64
+	FromCache  bool
+}
+
+// OnDeletedMessages streams messages permanently deleted by users.
+// Messages which just became inaccessible or were dropped from the cache are skipped, use OnAllDeletedMessages to get them too.
+func (client *Client) OnDeletedMessages(capacity int) (<-chan DeletedMessages, func()) {
+	return client.onDeletedMessages(capacity, false)
+}
+
+// OnAllDeletedMessages streams every updateDeleteMessages, including cache-only and non-permanent deletions.
+func (client *Client) OnAllDeletedMessages(capacity int) (<-chan DeletedMessages, func()) {
+	return client.onDeletedMessages(capacity, true)
+}
+
+func (client *Client) onDeletedMessages(capacity int, all bool) (<-chan DeletedMessages, func()) {
+	deleted := make(chan DeletedMessages, capacity)
+
+	stop := client.subscribe(capacity, func(update Type, done <-chan struct{}) {
+		upd, ok := update.(*UpdateDeleteMessages)
+		if !ok {
+			return
+		}
+		if !all && (!upd.IsPermanent || upd.FromCache) {
+			return
+		}
+
+		select {
+		case deleted <- DeletedMessages{
+			ChatId:     upd.ChatId,
+			MessageIds: upd.MessageIds,
+			FromCache:  upd.FromCache,
+		}:
+		case <-done:
+		}
+	}, func() {
+		close(deleted)
+	})
+
+	return deleted, stop
+}
+
+// OnChatMemberChanges streams changes of chat members, e.g. joins, leaves and promotions, see ChatMemberChange.
+func (client *Client) OnChatMemberChanges(capacity int) (<-chan *UpdateChatMember, func()) {
+	changes := make(chan *UpdateChatMember, capacity)
+
+	stop := client.subscribe(capacity, func(update Type, done <-chan struct{}) {
+		upd, ok := update.(*UpdateChatMember)
+		if !ok {
+			return
+		}
+
+		select {
+		case changes <- upd:
+		case <-done:
+		}
+	}, func() {
+		close(changes)
+	})
+
+	return changes, stop
+}
+
+type MemberChange string
+
+const (
+	MemberJoined   MemberChange = "joined"
+	MemberLeft     MemberChange = "left"
+	MemberBanned   MemberChange = "banned"
+	MemberPromoted MemberChange = "promoted"
+	MemberDemoted  MemberChange = "demoted"
+	MemberChanged  MemberChange = "changed"
+)
+
+func isChatMember(status ChatMemberStatus) bool {
+	switch status := status.(type) {
+	case *ChatMemberStatusCreator:
+		return status.IsMember
+	case *ChatMemberStatusAdministrator, *ChatMemberStatusMember:
+		return true
+	case *ChatMemberStatusRestricted:
+		return status.IsMember
+	}
+
+	return false
+}
+
+func isChatAdmin(status ChatMemberStatus) bool {
+	switch status.(type) {
+	case *ChatMemberStatusCreator, *ChatMemberStatusAdministrator:
+		return true
+	}
+
+	return false
+}
+
+// ChatMemberChange classifies the change of the member status.
+// MemberChanged is returned for other changes, e.g. new restrictions or administrator rights.
+func ChatMemberChange(update *UpdateChatMember) MemberChange {
+	oldStatus := update.OldChatMember.Status
+	newStatus := update.NewChatMember.Status
+
+	switch {
+	case isChatMember(newStatus) && !isChatMember(oldStatus):
+		return MemberJoined
+	case newStatus.ChatMemberStatusType() == TypeChatMemberStatusBanned:
+		return MemberBanned
+	case !isChatMember(newStatus) && isChatMember(oldStatus):
+		return MemberLeft
+	case isChatAdmin(newStatus) && !isChatAdmin(oldStatus):
+		return MemberPromoted
+	case !isChatAdmin(newStatus) && isChatAdmin(oldStatus):
+		return MemberDemoted
+	}
+
+	return MemberChanged
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func connectionState(state string) string {
+	return fmt.Sprintf(`{"@type":"updateConnectionState","state":{"@type":%q}}`, state)
+}
+
+func newMessageUpdate(id int64, date time.Time) string {
+	return fmt.Sprintf(`{"@type":"updateNewMessage","message":{"@type":"message","id":%d,"chat_id":1,"date":%d,`+
+		`"content":{"@type":"messageText","text":{"@type":"formattedText","text":"hi"}}}}`, id, date.Unix())
+}
+
+func TestIgnoreBacklogOlderThan(t *testing.T) {
+	client := NewTestClient(WithIgnoreBacklogOlderThan(time.Minute))
+	defer client.Stop()
+
+	listener := client.AddEventReceiver(&UpdateNewMessage{}, 10)
+	old := time.Now().Add(-time.Hour)
+
+	feed(t, client,
+		// Ready before catching up doesn't end the window
+		connectionState(TypeConnectionStateReady),
+		newMessageUpdate(1, old),
+		connectionState(TypeConnectionStateUpdating),
+		newMessageUpdate(2, old),
+		newMessageUpdate(3, time.Now()),
+		connectionState(TypeConnectionStateReady),
+		newMessageUpdate(4, old),
+	)
+
+	for _, id := range []int64{3, 4} {
+		if upd := receive(t, listener.Updates).(*UpdateNewMessage); upd.Message.Id != id {
+			t.Fatalf("expected message %d, got %d", id, upd.Message.Id)
+		}
+	}
+}
+
+func TestAuthKeyDropHandlerTracked(t *testing.T) {
+	var handled int32
+	client := NewTestClient(WithAuthKeyDropHandler(func(notification *UpdateServiceNotification) {
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&handled, 1)
+	}))
+
+	feed(t, client, `{"@type":"updateServiceNotification","type":"AUTH_KEY_DROP_DUPLICATE",`+
+		`"content":{"@type":"messageText","text":{"@type":"formattedText","text":"confirm"}}}`)
+	time.Sleep(10 * time.Millisecond)
+
+	client.Stop()
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Fatal("Stop returned before the handler")
+	}
+}
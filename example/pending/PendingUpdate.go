@@ -31,17 +31,15 @@ func main() {
 	tdlib.SetLogLevel(0)
 	tdlib.SetFilePath("./errors.txt")
 
-	// Set pending update list
-	tdlib.SetPendingUpdateType(&tdlib.UpdateNewMessage{})
-	// Of coz, you can set more than one type, depending on your needs
-	//tdlib.SetPendingUpdateType(&tdlib.UpdateNewMessage{}, &tdlib.UpdateMessageEdited{}, &tdlib.UpdateDeleteMessages{})
-
 	botToken := "your_bot_token"
 	authorizer := tdlib.BotAuthorizer(botToken)
 
 	authorizer.TdlibParameters <- GetTdParameters()
 
-	client, err := tdlib.NewClient(authorizer)
+	// Set pending update list
+	// Of coz, you can set more than one type, depending on your needs
+	//tdlib.WithPendingUpdateType(&tdlib.UpdateNewMessage{}, &tdlib.UpdateMessageEdited{}, &tdlib.UpdateDeleteMessages{})
+	client, err := tdlib.NewClient(authorizer, tdlib.WithPendingUpdateType(&tdlib.UpdateNewMessage{}))
 	if err != nil {
 		log.Fatalf("NewClient error: %s", err)
 	}
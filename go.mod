@@ -1,8 +1,14 @@
 module github.com/c0re100/gotdlib
 
-go 1.16
+go 1.18
 
 require (
 	github.com/google/uuid v1.3.1
+	go.uber.org/goleak v1.3.0
 	golang.org/x/crypto v0.13.0
 )
+
+require (
+	golang.org/x/sys v0.12.0 // indirect
+	golang.org/x/term v0.12.0 // indirect
+)